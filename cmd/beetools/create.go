@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"github.com/pippolo84/beetools/internal/torrent"
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+func create(ctx context.Context, w io.Writer, path string, b *torrent.Builder) error {
+	torrent, err := b.BuildContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	enc := bencode.NewEncoder(w)
+	return enc.Encode(torrent.ToDict())
+}
+
+// createFile is like create, but writes the torrent to the file output,
+// that is created only once the torrent is built.
+func createFile(ctx context.Context, output string, path string, b *torrent.Builder) error {
+	var buf bytes.Buffer
+	if err := create(ctx, &buf, path, b); err != nil {
+		return err
+	}
+
+	return os.WriteFile(output, buf.Bytes(), 0666)
+}
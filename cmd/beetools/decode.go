@@ -2,18 +2,57 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 
 	"github.com/pippolo84/beetools/internal/torrent"
+	"github.com/pippolo84/beetools/pkg/bencode"
 )
 
 func decode(w io.Writer, r io.Reader) error {
+	v, err := decodeValue(r)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// decodeValue decodes the bencode value making up the whole input.
+func decodeValue(r io.Reader) (bencode.Value, error) {
+	dec := bencode.NewDecoder(r)
+	dec.UseBigInt()
+
+	var v bencode.Value
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	// a corrupt or concatenated input must not be decoded partially
+	off := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &bencode.SyntaxError{Offset: off, Expected: "end of input", Err: bencode.ErrTrailingData}
+	}
+
+	return v, nil
+}
+
+func decodeTorrent(w io.Writer, r io.Reader) error {
 	torrent, err := torrent.NewTorrent(r)
 	if err != nil {
 		return err
 	}
 
 	if err := json.NewEncoder(w).Encode(torrent); err != nil {
+		// report the invalid field, without the details of encoding/json
+		var merr *json.MarshalerError
+		if errors.As(err, &merr) {
+			return merr.Unwrap()
+		}
 		return err
 	}
 
@@ -9,6 +9,24 @@ import (
 )
 
 func encode(w io.Writer, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	v, err := bencode.ParseJSON(data)
+	if err != nil {
+		return err
+	}
+
+	if err := bencode.NewEncoder(w).Encode(v); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func encodeTorrent(w io.Writer, r io.Reader) error {
 	var t torrent.Torrent
 	if err := json.NewDecoder(r).Decode(&t); err != nil {
 		return err
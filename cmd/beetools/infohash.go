@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pippolo84/beetools/internal/torrent"
+)
+
+func infohash(w io.Writer, r io.Reader, name string, asBase32 bool) error {
+	torrent, err := torrent.NewTorrent(r)
+	if err != nil {
+		return err
+	}
+
+	// a hybrid torrent has both hashes, the v1 one first
+	var hashes []string
+	if torrent.Info.IsV1() {
+		hash := torrent.InfoHash()
+		if asBase32 {
+			hashes = append(hashes, hash.Base32())
+		} else {
+			hashes = append(hashes, hash.Hex())
+		}
+	}
+	if torrent.Info.IsV2() {
+		hash := torrent.InfoHashV2()
+		if asBase32 {
+			hashes = append(hashes, hash.Base32())
+		} else {
+			hashes = append(hashes, hash.Hex())
+		}
+	}
+
+	for _, hash := range hashes {
+		fmt.Fprintf(w, "%s  %s\n", hash, name)
+	}
+
+	return nil
+}
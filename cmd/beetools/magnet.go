@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/pippolo84/beetools/internal/magnet"
+	"github.com/pippolo84/beetools/internal/torrent"
+)
+
+func magnetLink(w io.Writer, r io.Reader, selectOnly string, peers []string) error {
+	torrent, err := torrent.NewTorrent(r)
+	if err != nil {
+		return err
+	}
+
+	m := magnet.New(torrent)
+	if selectOnly != "" {
+		if m.SelectOnly, err = magnet.ParseSelectOnly(selectOnly); err != nil {
+			return err
+		}
+	}
+	for _, pe := range peers {
+		if _, _, err := net.SplitHostPort(pe); err != nil {
+			return fmt.Errorf("invalid peer %q: %w", pe, err)
+		}
+	}
+	m.Peers = peers
+
+	fmt.Fprintln(w, m)
+
+	return nil
+}
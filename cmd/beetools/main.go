@@ -1,19 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"time"
 
+	"github.com/pippolo84/beetools/internal/torrent"
 	"github.com/spf13/cobra"
 )
 
 func main() {
+	var encodeAsTorrent bool
 	encodeCmd := &cobra.Command{
 		Use:   "encode",
 		Short: "Encode data from JSON",
-		Long:  "Encode data from JSON to bencode format.",
-		Args:  cobra.RangeArgs(0, 2),
+		Long: `Encode data from JSON to bencode format.
+
+The JSON data must use the representation produced by the decode command,
+unless the --torrent flag is given.`,
+		Args: cobra.RangeArgs(0, 2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				r io.Reader
@@ -42,18 +50,38 @@ func main() {
 				w = out
 			}
 
-			if err := encode(w, r); err != nil {
+			encodeFunc := encode
+			if encodeAsTorrent {
+				encodeFunc = encodeTorrent
+			}
+
+			if err := encodeFunc(w, r); err != nil {
 				fmt.Fprintf(os.Stderr, "encode error: %v\n", err)
 			}
 			return nil
 		},
 	}
 
+	encodeCmd.Flags().BoolVar(
+		&encodeAsTorrent,
+		"torrent",
+		false,
+		"encode a torrent from the JSON produced by decode --torrent",
+	)
+
+	var decodeAsTorrent bool
 	decodeCmd := &cobra.Command{
 		Use:   "decode",
 		Short: "Decode data from bencode",
-		Long:  "Decode data from bencode format to JSON.",
-		Args:  cobra.RangeArgs(0, 2),
+		Long: `Decode data from bencode format to JSON.
+
+Any bencode value is decoded to a lossless JSON representation, where
+bytestrings that are not valid UTF-8 become {"$bytes":"<base64>"} objects
+and dict keys starting with "$" have the "$" doubled. With the --torrent
+flag, the data is decoded as a .torrent file instead: torrents with string
+fields that are not valid UTF-8, like the name of a file, are rejected,
+since they cannot be encoded back unchanged.`,
+		Args: cobra.RangeArgs(0, 2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				r io.Reader
@@ -82,13 +110,25 @@ func main() {
 				w = out
 			}
 
-			if err := decode(w, r); err != nil {
+			decodeFunc := decode
+			if decodeAsTorrent {
+				decodeFunc = decodeTorrent
+			}
+
+			if err := decodeFunc(w, r); err != nil {
 				fmt.Fprintf(os.Stderr, "decode error: %v\n", err)
 			}
 			return nil
 		},
 	}
 
+	decodeCmd.Flags().BoolVar(
+		&decodeAsTorrent,
+		"torrent",
+		false,
+		"decode the data as a .torrent file",
+	)
+
 	showCmd := &cobra.Command{
 		Use:   "show",
 		Short: "show data from bencode-encoded data",
@@ -115,6 +155,295 @@ func main() {
 		},
 	}
 
+	queryCmd := &cobra.Command{
+		Use:   "query <expr> [file]",
+		Short: "Query values inside bencode-encoded data",
+		Long: `Print as JSON the values selected by a path expression inside
+bencode-encoded data, one per line.
+
+A path expression is a sequence of dict keys, separated by dots, and of list
+indices in brackets. "*" and "[*]" select every element of a list or dict,
+while keys containing dots or brackets can be quoted, as in ["key.name"].
+For example: info.files[*].path or "info.piece length".`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader
+
+			r = os.Stdin
+			if len(args) > 1 {
+				in, err := os.Open(args[1])
+				if err != nil {
+					return err
+				}
+				defer in.Close()
+
+				r = in
+			}
+
+			if err := query(os.Stdout, r, args[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "query error: %v\n", err)
+			}
+			return nil
+		},
+	}
+
+	var infohashBase32 bool
+	infohashCmd := &cobra.Command{
+		Use:   "infohash [file...]",
+		Short: "Print the info-hash of .torrent files",
+		Long: `Print the info-hash of each .torrent file, followed by its name,
+one per line. Without files, the .torrent file is read from stdin.
+
+The info-hash is the SHA-1 hash of the info dict exactly as it is encoded
+in the file, printed in hexadecimal or, with the --base32 flag, in base32.
+For v2 torrents the SHA-256 info-hash is printed instead, while hybrid
+torrents get two lines, the v1 info-hash first.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				if err := infohash(os.Stdout, os.Stdin, "-", infohashBase32); err != nil {
+					fmt.Fprintf(os.Stderr, "infohash error: %v\n", err)
+				}
+				return nil
+			}
+
+			for _, name := range args {
+				in, err := os.Open(name)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "infohash error: %v\n", err)
+					continue
+				}
+
+				if err := infohash(os.Stdout, in, name, infohashBase32); err != nil {
+					fmt.Fprintf(os.Stderr, "infohash error: %s: %v\n", name, err)
+				}
+				in.Close()
+			}
+			return nil
+		},
+	}
+
+	infohashCmd.Flags().BoolVar(
+		&infohashBase32,
+		"base32",
+		false,
+		"print the info-hash in base32 instead of hexadecimal",
+	)
+
+	var (
+		magnetSelectOnly string
+		magnetPeers      []string
+	)
+	magnetCmd := &cobra.Command{
+		Use:   "magnet [file]",
+		Short: "Print the magnet link of a .torrent file",
+		Long: `Print the magnet link of a .torrent file, holding its info-hashes,
+name, trackers and web seeds.
+
+The --select flag restricts the download to some of the files, given as
+a comma separated list of file indices and ranges, e.g. 0,2,4-6, while the
+--peer flag adds the address of a peer to connect to.`,
+		Args: cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader
+
+			r = os.Stdin
+			if len(args) > 0 {
+				in, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer in.Close()
+
+				r = in
+			}
+
+			if err := magnetLink(os.Stdout, r, magnetSelectOnly, magnetPeers); err != nil {
+				fmt.Fprintf(os.Stderr, "magnet error: %v\n", err)
+			}
+			return nil
+		},
+	}
+
+	magnetCmd.Flags().StringVar(
+		&magnetSelectOnly,
+		"select",
+		"",
+		"indices of the files to download, e.g. 0,2,4-6",
+	)
+	magnetCmd.Flags().StringArrayVar(
+		&magnetPeers,
+		"peer",
+		nil,
+		"address of a peer to connect to, as host:port",
+	)
+
+	var (
+		builder        torrent.Builder
+		createDate     int64
+		createNoDate   bool
+		createProgress bool
+	)
+	createCmd := &cobra.Command{
+		Use:   "create <path> [file]",
+		Short: "Create a .torrent file",
+		Long: `Create a .torrent file of the file or directory at path.
+
+The files of a directory are added in lexical order, skipping anything
+that is not a regular file. Unless given with the --piece-length flag,
+the piece length is chosen according to the total size of the files.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case createNoDate:
+				builder.CreationDate = time.Time{}
+			case createDate != 0:
+				builder.CreationDate = time.Unix(createDate, 0)
+			default:
+				builder.CreationDate = time.Now()
+			}
+
+			if createProgress {
+				builder.Hasher.Progress = printProgress(os.Stderr)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			var err error
+			if len(args) > 1 {
+				err = createFile(ctx, args[1], args[0], &builder)
+			} else {
+				err = create(ctx, os.Stdout, args[0], &builder)
+			}
+			if createProgress {
+				fmt.Fprintln(os.Stderr)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "create error: %v\n", err)
+			}
+			return nil
+		},
+	}
+
+	createCmd.Flags().Int64Var(
+		&builder.PieceLength,
+		"piece-length",
+		0,
+		"length of the pieces in bytes, a power of two between 16 KiB and 16 MiB",
+	)
+	createCmd.Flags().StringArrayVar(
+		&builder.Trackers,
+		"tracker",
+		nil,
+		"URL of a tracker, the first one is the announce URL",
+	)
+	createCmd.Flags().StringArrayVar(
+		&builder.WebSeeds,
+		"web-seed",
+		nil,
+		"URL of a web seed",
+	)
+	createCmd.Flags().StringVar(
+		&builder.Comment,
+		"comment",
+		"",
+		"comment of the torrent",
+	)
+	createCmd.Flags().BoolVar(
+		&builder.Private,
+		"private",
+		false,
+		"get peers only from the trackers",
+	)
+	createCmd.Flags().StringVar(
+		&builder.Source,
+		"source",
+		"",
+		"source of the torrent, stored in the info dict",
+	)
+	createCmd.Flags().StringVar(
+		&builder.CreatedBy,
+		"created-by",
+		"beetools",
+		"name of the program creating the torrent, empty to omit it",
+	)
+	createCmd.Flags().Int64Var(
+		&createDate,
+		"creation-date",
+		0,
+		"creation date as a Unix timestamp, instead of the current time",
+	)
+	createCmd.Flags().BoolVar(
+		&createNoDate,
+		"no-creation-date",
+		false,
+		"omit the creation date",
+	)
+	createCmd.Flags().IntVar(
+		&builder.Hasher.Workers,
+		"workers",
+		0,
+		"number of goroutines hashing the pieces, 0 for one per CPU",
+	)
+	createCmd.Flags().BoolVar(
+		&createProgress,
+		"progress",
+		false,
+		"print the hashing progress to stderr",
+	)
+
+	var (
+		hasher         torrent.Hasher
+		verifyProgress bool
+	)
+	verifyCmd := &cobra.Command{
+		Use:   "verify <file.torrent> <path>",
+		Short: "Verify local files against a .torrent file",
+		Long: `Verify the content at path, the file of a single-file torrent or the
+directory of a multi-file one, against the piece hashes of a .torrent file,
+printing the pieces that do not match.
+
+Missing files and the missing part of short files are read as zeros, so
+their pieces do not match.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			if verifyProgress {
+				hasher.Progress = printProgress(os.Stderr)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			err = verify(ctx, os.Stdout, in, args[1], &hasher)
+			if verifyProgress {
+				fmt.Fprintln(os.Stderr)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "verify error: %v\n", err)
+			}
+			return nil
+		},
+	}
+
+	verifyCmd.Flags().IntVar(
+		&hasher.Workers,
+		"workers",
+		0,
+		"number of goroutines hashing the pieces, 0 for one per CPU",
+	)
+	verifyCmd.Flags().BoolVar(
+		&verifyProgress,
+		"progress",
+		false,
+		"print the hashing progress to stderr",
+	)
+
 	rootCmd := &cobra.Command{
 		Use:   "beetools",
 		Short: "beetools is a set of tools to manage bencode format",
@@ -125,6 +454,11 @@ from bencode format`,
 	rootCmd.AddCommand(encodeCmd)
 	rootCmd.AddCommand(decodeCmd)
 	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(infohashCmd)
+	rootCmd.AddCommand(magnetCmd)
+	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(verifyCmd)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 	}
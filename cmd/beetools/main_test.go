@@ -2,100 +2,394 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
+	"crypto/sha1"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/pippolo84/beetools/internal/torrent"
+	"github.com/pippolo84/beetools/pkg/bencode"
 )
 
+var encodeDecodeTestCases = []struct {
+	name   string
+	decode func(w io.Writer, r io.Reader) error
+	encode func(w io.Writer, r io.Reader) error
+}{
+	{
+		name:   "generic",
+		decode: decode,
+		encode: encode,
+	},
+	{
+		name:   "torrent",
+		decode: decodeTorrent,
+		encode: encodeTorrent,
+	},
+}
+
 func TestEncodeDecode(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
 	}
 
-	in, err := os.Open(
-		filepath.Join(
-			"testdata",
-			"debian-10.8.0-amd64-netinst.iso.torrent",
-		),
-	)
-	if err != nil {
-		t.Fatal(err)
+	for _, tc := range encodeDecodeTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := os.Open(
+				filepath.Join(
+					"testdata",
+					"debian-10.8.0-amd64-netinst.iso.torrent",
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				in.Close()
+			})
+
+			testDir := t.TempDir()
+
+			testJSON := filepath.Join(testDir, "test.json")
+			out, err := os.Create(testJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				out.Close()
+			})
+
+			if err := tc.decode(out, in); err != nil {
+				t.Fatal(err)
+			}
+			if err := out.Sync(); err != nil {
+				t.Fatal(err)
+			}
+
+			inJSON, err := os.Open(testJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testTorrent := filepath.Join(testDir, "test.torrent")
+			outTorrent, err := os.Create(testTorrent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				outTorrent.Close()
+			})
+
+			if err := tc.encode(outTorrent, inJSON); err != nil {
+				t.Fatal(err)
+			}
+			if err := out.Sync(); err != nil {
+				t.Fatal(err)
+			}
+
+			golden, err := os.Open(filepath.Join(
+				"testdata",
+				"debian-10.8.0-amd64-netinst.iso.torrent",
+			))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				golden.Close()
+			})
+
+			goldenHash := md5.New()
+			if _, err := io.Copy(goldenHash, golden); err != nil {
+				t.Fatal(err)
+			}
+
+			generated, err := os.Open(testTorrent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				generated.Close()
+			})
+
+			generatedHash := md5.New()
+			if _, err := io.Copy(generatedHash, generated); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(goldenHash.Sum(nil), generatedHash.Sum(nil)) {
+				t.Fatal("md5sum of generated torrent differs")
+			}
+		})
 	}
-	t.Cleanup(func() {
-		in.Close()
-	})
+}
 
-	testDir := t.TempDir()
+var genericDecodeTestCases = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{
+		name:     "tracker response",
+		input:    "d8:completei5e8:intervali1800e5:peers6:\x0a\x00\x00\x01\x1a\xe1e",
+		expected: `{"complete":5,"interval":1800,"peers":{"$bytes":"CgAAARrh"}}` + "\n",
+	},
+	{
+		name:     "list",
+		input:    "li1e3:twoe",
+		expected: `[1,"two"]` + "\n",
+	},
+}
 
-	testJSON := filepath.Join(testDir, "test.json")
-	out, err := os.Create(testJSON)
-	if err != nil {
-		t.Fatal(err)
+func TestDecodeEncodeGeneric(t *testing.T) {
+	for _, tc := range genericDecodeTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := decode(&out, strings.NewReader(tc.input)); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Fatalf("expected %q got %q\n", tc.expected, out.String())
+			}
+
+			var encoded bytes.Buffer
+			if err := encode(&encoded, &out); err != nil {
+				t.Fatal(err)
+			}
+
+			if encoded.String() != tc.input {
+				t.Fatalf("expected %q got %q\n", tc.input, encoded.String())
+			}
+		})
 	}
-	t.Cleanup(func() {
-		out.Close()
-	})
+}
 
-	if err := decode(out, in); err != nil {
-		t.Fatal(err)
+func TestDecodeTrailingData(t *testing.T) {
+	for _, input := range []string{"li1ee3:abc", "i1ei2e", "d1:ai1eex"} {
+		var out bytes.Buffer
+		if err := decode(&out, strings.NewReader(input)); !errors.Is(err, bencode.ErrTrailingData) {
+			t.Fatalf("%q: expected error %v, got %v", input, bencode.ErrTrailingData, err)
+		}
 	}
-	if err := out.Sync(); err != nil {
-		t.Fatal(err)
+}
+
+func TestQueryTrailingData(t *testing.T) {
+	for _, input := range []string{"li1ee3:abc", "i1ei2e", "d1:ai1eex"} {
+		var out bytes.Buffer
+		if err := query(&out, strings.NewReader(input), ""); !errors.Is(err, bencode.ErrTrailingData) {
+			t.Fatalf("%q: expected error %v, got %v", input, bencode.ErrTrailingData, err)
+		}
 	}
+}
 
-	inJSON, err := os.Open(testJSON)
-	if err != nil {
-		t.Fatal(err)
+var queryTestCases = []struct {
+	name     string
+	expr     string
+	expected string
+}{
+	{
+		name:     "key with spaces",
+		expr:     "info.piece length",
+		expected: "262144\n",
+	},
+	{
+		name:     "index",
+		expr:     "httpseeds[0]",
+		expected: "\"https://cdimage.debian.org/cdimage/release/10.8.0//srv/cdbuilder.debian.org/dst/deb-cd/weekly-builds/amd64/iso-cd/debian-10.8.0-amd64-netinst.iso\"\n",
+	},
+	{
+		name:     "missing key",
+		expr:     "info.files[*].path",
+		expected: "",
+	},
+}
+
+func TestQuery(t *testing.T) {
+	for _, tc := range queryTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := os.Open(
+				filepath.Join(
+					"testdata",
+					"debian-10.8.0-amd64-netinst.iso.torrent",
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				in.Close()
+			})
+
+			var out bytes.Buffer
+			if err := query(&out, in, tc.expr); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Fatalf("expected %q got %q\n", tc.expected, out.String())
+			}
+		})
 	}
+}
 
-	testTorrent := filepath.Join(testDir, "test.torrent")
-	outTorrent, err := os.Create(testTorrent)
-	if err != nil {
-		t.Fatal(err)
+var infohashTestCases = []struct {
+	name     string
+	base32   bool
+	expected string
+}{
+	{
+		name:     "hex",
+		expected: "4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7  debian.torrent\n",
+	},
+	{
+		name:     "base32",
+		base32:   true,
+		expected: "ICIMHQVDSSSJS5G7XPZM46WQ3M6N5XOX  debian.torrent\n",
+	},
+}
+
+func TestInfohash(t *testing.T) {
+	for _, tc := range infohashTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := os.Open(
+				filepath.Join(
+					"testdata",
+					"debian-10.8.0-amd64-netinst.iso.torrent",
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				in.Close()
+			})
+
+			var out bytes.Buffer
+			if err := infohash(&out, in, "debian.torrent", tc.base32); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Fatalf("expected %q got %q\n", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+var magnetTestCases = []struct {
+	name       string
+	selectOnly string
+	peers      []string
+	expected   string
+}{
+	{
+		name: "plain",
+		expected: "magnet:?xt=urn:btih:4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7" +
+			"&dn=debian-10.8.0-amd64-netinst.iso&tr=http%3A%2F%2Fbttracker.debian.org%3A6969%2Fannounce\n",
+	},
+	{
+		name:       "select only and peers",
+		selectOnly: "0",
+		peers:      []string{"10.0.0.1:6881"},
+		expected: "magnet:?xt=urn:btih:4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7" +
+			"&dn=debian-10.8.0-amd64-netinst.iso&tr=http%3A%2F%2Fbttracker.debian.org%3A6969%2Fannounce" +
+			"&so=0&x.pe=10.0.0.1%3A6881\n",
+	},
+}
+
+func TestMagnet(t *testing.T) {
+	for _, tc := range magnetTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := os.Open(
+				filepath.Join(
+					"testdata",
+					"debian-10.8.0-amd64-netinst.iso.torrent",
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				in.Close()
+			})
+
+			var out bytes.Buffer
+			if err := magnetLink(&out, in, tc.selectOnly, tc.peers); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Fatalf("expected %q got %q\n", tc.expected, out.String())
+			}
+		})
 	}
-	t.Cleanup(func() {
-		outTorrent.Close()
-	})
+}
 
-	if err := encode(outTorrent, inJSON); err != nil {
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
 		t.Fatal(err)
 	}
-	if err := out.Sync(); err != nil {
+
+	var out bytes.Buffer
+	b := torrent.Builder{
+		Trackers:     []string{"http://tracker/announce"},
+		CreationDate: time.Unix(1612616374, 0),
+	}
+	if err := create(context.Background(), &out, filepath.Join(dir, "file"), &b); err != nil {
 		t.Fatal(err)
 	}
 
-	golden, err := os.Open(filepath.Join(
-		"testdata",
-		"debian-10.8.0-amd64-netinst.iso.torrent",
-	))
-	if err != nil {
+	hash := sha1.Sum([]byte("data"))
+	expected := "d8:announce23:http://tracker/announce13:creation datei1612616374e" +
+		"4:infod6:lengthi4e4:name4:file12:piece lengthi16384e6:pieces20:" + string(hash[:]) + "ee"
+	if out.String() != expected {
+		t.Fatalf("expected %q got %q\n", expected, out.String())
+	}
+}
+
+func TestCreateFileError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
 		t.Fatal(err)
 	}
-	t.Cleanup(func() {
-		golden.Close()
-	})
 
-	goldenHash := md5.New()
-	if _, err := io.Copy(goldenHash, golden); err != nil {
+	output := filepath.Join(dir, "file.torrent")
+	b := torrent.Builder{PieceLength: 1}
+	if err := createFile(context.Background(), output, filepath.Join(dir, "file"), &b); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// a failed build must not leave an empty .torrent file behind
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("expected %s not to exist, got %v\n", output, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
 		t.Fatal(err)
 	}
 
-	generated, err := os.Open(testTorrent)
-	if err != nil {
+	var torrentFile bytes.Buffer
+	if err := create(context.Background(), &torrentFile, filepath.Join(dir, "file"), &torrent.Builder{}); err != nil {
 		t.Fatal(err)
 	}
-	t.Cleanup(func() {
-		generated.Close()
-	})
 
-	generatedHash := md5.New()
-	if _, err := io.Copy(generatedHash, generated); err != nil {
+	var out bytes.Buffer
+	if err := verify(context.Background(), &out, &torrentFile, filepath.Join(dir, "file"), &torrent.Hasher{}); err != nil {
 		t.Fatal(err)
 	}
 
-	if !bytes.Equal(goldenHash.Sum(nil), generatedHash.Sum(nil)) {
-		t.Fatal("md5sum of generated torrent differs")
+	expected := "1 of 1 pieces OK\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q got %q\n", expected, out.String())
 	}
 }
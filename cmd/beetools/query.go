@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+func query(w io.Writer, r io.Reader, expr string) error {
+	root, err := decodeValue(r)
+	if err != nil {
+		return err
+	}
+
+	values, err := bencode.Query(root, expr)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+
+	"github.com/pippolo84/beetools/internal/torrent"
+)
+
+func verify(ctx context.Context, w io.Writer, r io.Reader, path string, h *torrent.Hasher) error {
+	torrent, err := torrent.NewTorrent(r)
+	if err != nil {
+		return err
+	}
+
+	bad, err := torrent.Info.Verify(ctx, path, h)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range bad {
+		fmt.Fprintf(w, "piece %d: hash mismatch\n", index)
+	}
+	// the pieces hashed beyond the ones of the torrent are
+	// compared too, and reported as mismatching
+	total := len(torrent.Info.Pieces) / sha1.Size
+	if n := len(bad); n > 0 && bad[n-1] >= total {
+		total = bad[n-1] + 1
+	}
+	fmt.Fprintf(w, "%d of %d pieces OK\n", total-len(bad), total)
+
+	return nil
+}
+
+// printProgress returns a Hasher progress callback printing
+// the progress to w, on a single line.
+func printProgress(w io.Writer) func(pieces int, bytes int64) {
+	return func(pieces int, bytes int64) {
+		fmt.Fprintf(w, "\rhashed %d pieces, %d MiB", pieces, bytes>>20)
+	}
+}
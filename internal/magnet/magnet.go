@@ -0,0 +1,251 @@
+// Package magnet builds and parses magnet links, as defined in BEP 9,
+// BEP 53 and BEP 52.
+package magnet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pippolo84/beetools/internal/torrent"
+)
+
+const (
+	// scheme is the prefix of every magnet link
+	scheme = "magnet:?"
+
+	// btihPrefix is the prefix of the exact topic holding a v1 info-hash
+	btihPrefix = "urn:btih:"
+	// btmhPrefix is the prefix of the exact topic holding a v2 info-hash,
+	// in multihash format
+	btmhPrefix = "urn:btmh:"
+	// sha256Multihash is the multihash prefix of a SHA-256 hash: the
+	// function code 0x12 followed by the 0x20 bytes length
+	sha256Multihash = "1220"
+)
+
+var (
+	// ErrInvalidMagnet is the error returned when a string
+	// is not a valid magnet link
+	ErrInvalidMagnet = errors.New("invalid magnet link")
+	// ErrNoInfoHash is the error returned when a magnet link
+	// has no BitTorrent info-hash
+	ErrNoInfoHash = errors.New("no info-hash in magnet link")
+)
+
+// Magnet is a magnet link to a torrent.
+type Magnet struct {
+	// InfoHash is the v1 info-hash of the torrent (xt=urn:btih)
+	InfoHash *torrent.InfoHash
+	// InfoHashV2 is the v2 info-hash of the torrent (xt=urn:btmh)
+	InfoHashV2 *torrent.InfoHashV2
+	// DisplayName is the name of the torrent (dn)
+	DisplayName string
+	// Trackers are the URLs of the trackers of the torrent (tr)
+	Trackers []string
+	// WebSeeds are the URLs of the web seeds of the torrent (ws)
+	WebSeeds []string
+	// SelectOnly are the indices of the files to download (so)
+	SelectOnly []FileRange
+	// Peers are the addresses, as host:port, of peers to connect to (x.pe)
+	Peers []string
+}
+
+// FileRange is an inclusive range of file indices.
+type FileRange struct {
+	First, Last int
+}
+
+// String satisfies the fmt.Stringer interface.
+func (r FileRange) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(r.First)
+	}
+
+	return strconv.Itoa(r.First) + "-" + strconv.Itoa(r.Last)
+}
+
+// New returns the magnet link to t, holding its info-hashes, name,
+// trackers and web seeds.
+func New(t *torrent.Torrent) *Magnet {
+	m := &Magnet{
+		DisplayName: t.Info.Name,
+		Trackers:    t.Trackers(),
+		WebSeeds:    t.WebSeeds(),
+	}
+	if t.Info.IsV1() {
+		hash := t.InfoHash()
+		m.InfoHash = &hash
+	}
+	if t.Info.IsV2() {
+		hash := t.InfoHashV2()
+		m.InfoHashV2 = &hash
+	}
+
+	return m
+}
+
+// String satisfies the fmt.Stringer interface, returning the magnet link.
+func (m *Magnet) String() string {
+	var params []string
+
+	if m.InfoHash != nil {
+		params = append(params, "xt="+btihPrefix+m.InfoHash.Hex())
+	}
+	if m.InfoHashV2 != nil {
+		params = append(params, "xt="+btmhPrefix+sha256Multihash+m.InfoHashV2.Hex())
+	}
+	if m.DisplayName != "" {
+		params = append(params, "dn="+url.QueryEscape(m.DisplayName))
+	}
+	for _, tr := range m.Trackers {
+		params = append(params, "tr="+url.QueryEscape(tr))
+	}
+	for _, ws := range m.WebSeeds {
+		params = append(params, "ws="+url.QueryEscape(ws))
+	}
+	if len(m.SelectOnly) > 0 {
+		ranges := make([]string, 0, len(m.SelectOnly))
+		for _, r := range m.SelectOnly {
+			ranges = append(ranges, r.String())
+		}
+		params = append(params, "so="+strings.Join(ranges, ","))
+	}
+	for _, pe := range m.Peers {
+		params = append(params, "x.pe="+url.QueryEscape(pe))
+	}
+
+	return scheme + strings.Join(params, "&")
+}
+
+// Parse parses a magnet link.
+//
+// Parse returns an error wrapping ErrInvalidMagnet if s is not a valid
+// magnet link, and wrapping ErrNoInfoHash if it does not hold any v1 or
+// v2 info-hash. Unknown parameters are ignored.
+func Parse(s string) (*Magnet, error) {
+	if !strings.HasPrefix(s, scheme) {
+		return nil, fmt.Errorf("%w: missing %q prefix", ErrInvalidMagnet, scheme)
+	}
+
+	params, err := url.ParseQuery(s[len(scheme):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMagnet, err)
+	}
+
+	m := &Magnet{
+		DisplayName: params.Get("dn"),
+		Trackers:    params["tr"],
+		WebSeeds:    params["ws"],
+	}
+	for _, xt := range params["xt"] {
+		if err := m.parseExactTopic(xt); err != nil {
+			return nil, err
+		}
+	}
+	if m.InfoHash == nil && m.InfoHashV2 == nil {
+		return nil, ErrNoInfoHash
+	}
+
+	for _, so := range params["so"] {
+		ranges, err := ParseSelectOnly(so)
+		if err != nil {
+			return nil, err
+		}
+		m.SelectOnly = append(m.SelectOnly, ranges...)
+	}
+
+	for _, pe := range params["x.pe"] {
+		if _, _, err := net.SplitHostPort(pe); err != nil {
+			return nil, fmt.Errorf("%w: peer %q: %v", ErrInvalidMagnet, pe, err)
+		}
+		m.Peers = append(m.Peers, pe)
+	}
+
+	return m, nil
+}
+
+// parseExactTopic parses the value of an xt parameter, ignoring
+// the topics that are not BitTorrent info-hashes.
+func (m *Magnet) parseExactTopic(xt string) error {
+	switch {
+	case strings.HasPrefix(xt, btihPrefix):
+		hash, err := parseInfoHash(xt[len(btihPrefix):])
+		if err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrInvalidMagnet, xt, err)
+		}
+		m.InfoHash = &hash
+	case strings.HasPrefix(xt, btmhPrefix):
+		mh := strings.ToLower(xt[len(btmhPrefix):])
+		if !strings.HasPrefix(mh, sha256Multihash) {
+			return fmt.Errorf("%w: %q: not a SHA-256 multihash", ErrInvalidMagnet, xt)
+		}
+
+		var hash torrent.InfoHashV2
+		buf, err := hex.DecodeString(mh[len(sha256Multihash):])
+		if err != nil || len(buf) != len(hash) {
+			return fmt.Errorf("%w: %q: invalid hash", ErrInvalidMagnet, xt)
+		}
+		copy(hash[:], buf)
+		m.InfoHashV2 = &hash
+	}
+
+	return nil
+}
+
+// parseInfoHash parses a v1 info-hash, either in hexadecimal
+// or in base32.
+func parseInfoHash(s string) (torrent.InfoHash, error) {
+	var (
+		hash torrent.InfoHash
+		buf  []byte
+		err  error
+	)
+
+	switch len(s) {
+	case hex.EncodedLen(len(hash)):
+		buf, err = hex.DecodeString(s)
+	case base32.StdEncoding.EncodedLen(len(hash)):
+		buf, err = base32.StdEncoding.DecodeString(strings.ToUpper(s))
+	default:
+		return hash, fmt.Errorf("invalid hash length %d", len(s))
+	}
+	if err != nil {
+		return hash, err
+	}
+	copy(hash[:], buf)
+
+	return hash, nil
+}
+
+// ParseSelectOnly parses the value of a select-only parameter: a comma
+// separated list of file indices and inclusive ranges of indices, e.g.
+// "0,2,4-6".
+func ParseSelectOnly(s string) ([]FileRange, error) {
+	var ranges []FileRange
+	for _, elem := range strings.Split(s, ",") {
+		first, last := elem, elem
+		if i := strings.IndexByte(elem, '-'); i != -1 {
+			first, last = elem[:i], elem[i+1:]
+		}
+
+		var (
+			r   FileRange
+			err error
+		)
+		if r.First, err = strconv.Atoi(first); err != nil || r.First < 0 {
+			return nil, fmt.Errorf("%w: invalid select-only range %q", ErrInvalidMagnet, elem)
+		}
+		if r.Last, err = strconv.Atoi(last); err != nil || r.Last < r.First {
+			return nil, fmt.Errorf("%w: invalid select-only range %q", ErrInvalidMagnet, elem)
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
@@ -0,0 +1,112 @@
+package magnet
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pippolo84/beetools/internal/torrent"
+)
+
+const testTorrent = "d8:announce3:url13:announce-listll3:urlel4:url2ee4:infod6:lengthi10e4:name6:a file" +
+	"12:piece lengthi16e6:pieces20:01234567890123456789e8:url-list3:webe"
+
+func TestNew(t *testing.T) {
+	tor, err := torrent.NewTorrent(strings.NewReader(testTorrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "magnet:?xt=urn:btih:" + tor.InfoHash().Hex() + "&dn=a+file&tr=url&tr=url2&ws=web"
+	if got := New(tor).String(); got != expected {
+		t.Fatalf("expected %q got %q\n", expected, got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	v1 := torrent.InfoHash{0x01, 0x02, 0x03}
+	v2 := torrent.InfoHashV2{0x04, 0x05, 0x06}
+	m := &Magnet{
+		InfoHash:    &v1,
+		InfoHashV2:  &v2,
+		DisplayName: "name & more",
+		Trackers:    []string{"http://tracker/announce?a=1&b=2", "udp://tracker:80"},
+		WebSeeds:    []string{"http://web/"},
+		SelectOnly:  []FileRange{{0, 0}, {2, 2}, {4, 6}},
+		Peers:       []string{"10.0.0.1:6881", "[::1]:6881"},
+	}
+
+	link := m.String()
+	if !strings.Contains(link, "&so=0,2,4-6&") {
+		t.Fatalf("expected a select-only parameter got %q\n", link)
+	}
+
+	got, err := Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Fatalf("expected %+v got %+v\n", m, got)
+	}
+}
+
+func TestParseBase32(t *testing.T) {
+	m, err := Parse("magnet:?xt=urn:btih:ICIMHQVDSSSJS5G7XPZM46WQ3M6N5XOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7"
+	if got := m.InfoHash.Hex(); got != expected {
+		t.Fatalf("expected %q got %q\n", expected, got)
+	}
+}
+
+var parseErrorTestCases = []struct {
+	name  string
+	input string
+	err   error
+}{
+	{
+		"missing scheme",
+		"http://example.com/?xt=urn:btih:4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7",
+		ErrInvalidMagnet,
+	},
+	{
+		"no info-hash",
+		"magnet:?dn=name&xt=urn:sha1:4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7",
+		ErrNoInfoHash,
+	},
+	{
+		"short btih",
+		"magnet:?xt=urn:btih:4090c3c2",
+		ErrInvalidMagnet,
+	},
+	{
+		"btmh not sha256",
+		"magnet:?xt=urn:btmh:1114" + strings.Repeat("00", 20),
+		ErrInvalidMagnet,
+	},
+	{
+		"invalid select-only",
+		"magnet:?xt=urn:btih:4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7&so=3-1",
+		ErrInvalidMagnet,
+	},
+	{
+		"invalid peer",
+		"magnet:?xt=urn:btih:4090c3c2a394a49974dfbbf2ce7ad0db3cdeddd7&x.pe=10.0.0.1",
+		ErrInvalidMagnet,
+	},
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range parseErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v got %v\n", tc.err, err)
+			}
+		})
+	}
+}
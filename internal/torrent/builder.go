@@ -0,0 +1,199 @@
+package torrent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+const (
+	// MinPieceLength is the smallest piece length of a Builder.
+	MinPieceLength = 16 << 10
+	// MaxPieceLength is the largest piece length of a Builder.
+	MaxPieceLength = 16 << 20
+
+	// targetPieces is the number of pieces a Builder aims for
+	// when choosing the piece length
+	targetPieces = 1500
+)
+
+// Builder builds a v1 torrent from local files.
+type Builder struct {
+	// Hasher hashes the pieces of the files
+	Hasher Hasher
+	// PieceLength is the length of the pieces, a power of two between
+	// MinPieceLength and MaxPieceLength; if 0, it is chosen according
+	// to the total size of the files
+	PieceLength int64
+	// Trackers are the URLs of the trackers: the first one is the announce
+	// URL, and all of them are in the announce-list, one per tier
+	Trackers []string
+	// WebSeeds are the URLs of the web seeds, as defined in BEP 19
+	WebSeeds []string
+	Comment  string
+	// Private restricts the peers to the ones from the trackers
+	Private bool
+	// Source is stored in the info dict, so that torrents of the same
+	// files for different trackers have different info-hashes
+	Source string
+	// CreationDate is omitted if zero
+	CreationDate time.Time
+	// CreatedBy is the name of the program creating the torrent,
+	// omitted if empty
+	CreatedBy string
+}
+
+// Build returns a torrent of the file or the directory at path.
+//
+// The files of a directory are added in lexical order, skipping anything
+// that is not a regular file, like symbolic links. The name of the torrent
+// is the base name of path.
+func (b *Builder) Build(path string) (*Torrent, error) {
+	return b.BuildContext(context.Background(), path)
+}
+
+// BuildContext is like Build, but stops hashing the files as soon
+// as ctx is done, returning ctx.Err().
+func (b *Builder) BuildContext(ctx context.Context, path string) (*Torrent, error) {
+	if b.Private && len(b.Trackers) == 0 {
+		return nil, &ValidationError{Key: "announce", Err: ErrNoAnnounce}
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	// a relative path like "." has no meaningful base name
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := Info{
+		Name:    filepath.Base(abs),
+		Private: b.Private,
+	}
+	var files []localFile
+	if fi.IsDir() {
+		info.Files, files, err = walkFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(info.Files) == 0 {
+			return nil, ErrNoFiles
+		}
+	} else {
+		info.Length = fi.Size()
+		files = []localFile{{path, fi.Size()}}
+	}
+
+	info.PieceLength = b.PieceLength
+	if info.PieceLength == 0 {
+		info.PieceLength = PieceLengthFor(info.TotalLength())
+	}
+	if info.PieceLength < MinPieceLength || info.PieceLength > MaxPieceLength ||
+		info.PieceLength&(info.PieceLength-1) != 0 {
+		return nil, ErrInvalidPieceLength
+	}
+
+	r := newContentReader(files, false)
+	info.Pieces, err = b.Hasher.Hash(ctx, r, info.PieceLength)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Source != "" {
+		info.Extra = &bencode.Dict{}
+		info.Extra.Set("source", bencode.NewByteString(b.Source))
+	}
+
+	t := &Torrent{
+		Comment:      b.Comment,
+		CreationDate: b.CreationDate,
+		Info:         info,
+	}
+	if len(b.Trackers) > 0 {
+		t.Announce = b.Trackers[0]
+	}
+	t.Extra = b.extra()
+
+	return t, nil
+}
+
+// extra returns the top-level keys of the torrent not held by
+// the fields of Torrent, or nil if there are none.
+func (b *Builder) extra() *bencode.Dict {
+	var extra bencode.Dict
+
+	if len(b.Trackers) > 1 {
+		var tiers bencode.List
+		for _, tr := range b.Trackers {
+			tiers.Append(stringsToList([]string{tr}))
+		}
+		extra.Set("announce-list", tiers)
+	}
+	if len(b.WebSeeds) > 0 {
+		extra.Set("url-list", stringsToList(b.WebSeeds))
+	}
+	if b.CreatedBy != "" {
+		extra.Set("created by", bencode.NewByteString(b.CreatedBy))
+	}
+
+	if extra.Len() == 0 {
+		return nil
+	}
+
+	return &extra
+}
+
+// PieceLengthFor returns the piece length suitable for a torrent of
+// the given total size: a power of two between MinPieceLength and
+// MaxPieceLength, giving about 1500 pieces or less.
+func PieceLengthFor(size int64) int64 {
+	length := int64(MinPieceLength)
+	for length < MaxPieceLength && (size+length-1)/length > targetPieces {
+		length <<= 1
+	}
+
+	return length
+}
+
+// walkFiles returns the regular files inside the directory root, in
+// lexical order, both as torrent and as local files.
+func walkFiles(root string) ([]File, []localFile, error) {
+	var (
+		files []File
+		local []localFile
+	)
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{
+			Length: fi.Size(),
+			Path:   strings.Split(filepath.ToSlash(rel), "/"),
+		})
+		local = append(local, localFile{path, fi.Size()})
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return files, local, nil
+}
@@ -0,0 +1,214 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+// writeFiles creates the files in dir, with the given contents
+// by slash separated path.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// piecesOf returns the concatenated SHA-1 hashes of the pieces of data.
+func piecesOf(data []byte, pieceLength int) []byte {
+	pieces := []byte{}
+	for len(data) > 0 {
+		n := pieceLength
+		if n > len(data) {
+			n = len(data)
+		}
+		hash := sha1.Sum(data[:n])
+		pieces = append(pieces, hash[:]...)
+		data = data[n:]
+	}
+
+	return pieces
+}
+
+func TestBuilderDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "root")
+	a := bytes.Repeat([]byte("a"), MinPieceLength+10)
+	b := bytes.Repeat([]byte("b"), MinPieceLength)
+	writeFiles(t, dir, map[string]string{
+		"dir/a.txt": string(a),
+		"b.txt":     string(b),
+		"empty":     "",
+	})
+
+	builder := Builder{
+		Trackers:     []string{"http://t1/announce", "http://t2/announce"},
+		WebSeeds:     []string{"http://web/"},
+		Comment:      "comment",
+		Private:      true,
+		Source:       "src",
+		CreationDate: time.Unix(1612616374, 0),
+		CreatedBy:    "beetools",
+	}
+	torrent, err := builder.Build(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"root/b.txt", "root/dir/a.txt", "root/empty"}
+	if got := torrent.Info.Paths(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+	if got := torrent.Info.PieceLength; got != MinPieceLength {
+		t.Fatalf("expected %d got %d\n", MinPieceLength, got)
+	}
+	pieces := piecesOf(append(b, a...), MinPieceLength)
+	if !bytes.Equal(torrent.Info.Pieces, pieces) {
+		t.Fatalf("expected %x got %x\n", pieces, torrent.Info.Pieces)
+	}
+
+	// the built torrent must be valid
+	buf, err := bencode.Marshal(torrent.ToDict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := NewTorrent(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Trackers(); !reflect.DeepEqual(got, builder.Trackers) {
+		t.Fatalf("expected %v got %v\n", builder.Trackers, got)
+	}
+	if got := decoded.WebSeeds(); !reflect.DeepEqual(got, builder.WebSeeds) {
+		t.Fatalf("expected %v got %v\n", builder.WebSeeds, got)
+	}
+	if !decoded.Info.Private || decoded.Comment != "comment" || !decoded.CreationDate.Equal(builder.CreationDate) {
+		t.Fatalf("expected %+v got %+v\n", builder, decoded)
+	}
+	if got, expected := decoded.InfoHash(), torrent.InfoHash(); got != expected {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+}
+
+func TestBuilderName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "root")
+	writeFiles(t, dir, map[string]string{"file": "data"})
+
+	torrent, err := (&Builder{}).Build(dir + string(filepath.Separator) + ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if torrent.Info.Name != "root" {
+		t.Fatalf("expected %q got %q\n", "root", torrent.Info.Name)
+	}
+}
+
+func TestBuilderFile(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	writeFiles(t, dir, map[string]string{"file": string(data)})
+
+	builder := Builder{PieceLength: 32 << 10}
+	torrent, err := builder.Build(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if torrent.Info.IsMultiFile() || torrent.Info.Name != "file" || torrent.Info.Length != int64(len(data)) {
+		t.Fatalf("expected a single file torrent got %+v\n", torrent.Info)
+	}
+	pieces := piecesOf(data, 32<<10)
+	if !bytes.Equal(torrent.Info.Pieces, pieces) {
+		t.Fatalf("expected %x got %x\n", pieces, torrent.Info.Pieces)
+	}
+}
+
+var builderErrorTestCases = []struct {
+	name    string
+	builder Builder
+	files   map[string]string
+	err     error
+}{
+	{
+		name:    "piece length not a power of two",
+		builder: Builder{PieceLength: 3 << 14},
+		files:   map[string]string{"file": "data"},
+		err:     ErrInvalidPieceLength,
+	},
+	{
+		name:    "piece length too large",
+		builder: Builder{PieceLength: 2 * MaxPieceLength},
+		files:   map[string]string{"file": "data"},
+		err:     ErrInvalidPieceLength,
+	},
+	{
+		name:    "huge piece length",
+		builder: Builder{PieceLength: 1 << 62},
+		files:   map[string]string{"file": "data"},
+		err:     ErrInvalidPieceLength,
+	},
+	{
+		name:    "piece length too small",
+		builder: Builder{PieceLength: 1 << 10},
+		files:   map[string]string{"file": "data"},
+		err:     ErrInvalidPieceLength,
+	},
+	{
+		name:    "private without trackers",
+		builder: Builder{Private: true},
+		files:   map[string]string{"file": "data"},
+		err:     ErrNoAnnounce,
+	},
+	{
+		name:  "empty directory",
+		files: map[string]string{},
+		err:   ErrNoFiles,
+	},
+}
+
+func TestBuilderErrors(t *testing.T) {
+	for _, tc := range builderErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			_, err := tc.builder.Build(dir)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v got %v\n", tc.err, err)
+			}
+		})
+	}
+}
+
+var pieceLengthForTestCases = []struct {
+	size     int64
+	expected int64
+}{
+	{0, MinPieceLength},
+	{1500 * MinPieceLength, MinPieceLength},
+	{1500*MinPieceLength + 1, 2 * MinPieceLength},
+	{352321536, 256 << 10},
+	{1 << 50, MaxPieceLength},
+}
+
+func TestPieceLengthFor(t *testing.T) {
+	for _, tc := range pieceLengthForTestCases {
+		if got := PieceLengthFor(tc.size); got != tc.expected {
+			t.Fatalf("expected %d got %d\n", tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package torrent
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// localFile is a file of the content of a torrent in the local
+// file system.
+type localFile struct {
+	// path is empty for a padding file, made of zeros
+	path   string
+	length int64
+}
+
+// contentReader reads the content of a torrent from the local files,
+// one after the other, opening them only when needed.
+type contentReader struct {
+	files []localFile
+	// lenient reads missing files and the missing part of short files as
+	// zeros, and ignores the data of long files past their length
+	lenient bool
+	// long holds the indices of the long files read in lenient mode,
+	// and index is the index of the file being read
+	long  []int
+	index int
+
+	f *os.File
+	r *io.LimitedReader
+}
+
+// newContentReader returns a reader of the content made of files.
+func newContentReader(files []localFile, lenient bool) *contentReader {
+	return &contentReader{files: files, lenient: lenient}
+}
+
+// Read satisfies the io.Reader interface. Unless the reader is lenient,
+// Read returns an error if the size of a file is not its length in the
+// torrent.
+func (cr *contentReader) Read(p []byte) (int, error) {
+	for {
+		if cr.r == nil {
+			if len(cr.files) == 0 {
+				return 0, io.EOF
+			}
+			if err := cr.next(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := cr.r.Read(p)
+		if err == io.EOF && cr.f != nil {
+			// the file must end exactly at its length
+			_, err := cr.f.Read(make([]byte, 1))
+			short, long := cr.r.N > 0, err != io.EOF
+			cr.f.Close()
+			cr.f = nil
+
+			if (short || long) && !cr.lenient {
+				return n, fmt.Errorf("%s: %w", cr.files[0].path, ErrFileChanged)
+			}
+			if long {
+				cr.long = append(cr.long, cr.index)
+			}
+			if short {
+				cr.r.R = zeroReader{}
+				if n > 0 {
+					return n, nil
+				}
+				continue
+			}
+		}
+		if err == io.EOF {
+			cr.r = nil
+			cr.files = cr.files[1:]
+			cr.index++
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// next opens the next file.
+func (cr *contentReader) next() error {
+	file := cr.files[0]
+	cr.r = &io.LimitedReader{R: zeroReader{}, N: file.length}
+	if file.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		if cr.lenient && os.IsNotExist(err) {
+			return nil
+		}
+		cr.r = nil
+		return err
+	}
+	cr.f = f
+	cr.r.R = f
+
+	return nil
+}
+
+// Close closes the file being read, if any.
+func (cr *contentReader) Close() error {
+	cr.r = nil
+	if cr.f == nil {
+		return nil
+	}
+
+	f := cr.f
+	cr.f = nil
+	return f.Close()
+}
+
+// zeroReader is an endless source of zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
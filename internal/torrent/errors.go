@@ -0,0 +1,66 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMissingField is the error returned when a required
+	// field of a .torrent file is missing
+	ErrMissingField = errors.New("missing required field")
+	// ErrWrongType is the error returned when a field of a
+	// .torrent file has an unexpected bencode type
+	ErrWrongType = errors.New("wrong field type")
+	// ErrInvalidValue is the error returned when a field of a
+	// .torrent file has the right type but an invalid value
+	ErrInvalidValue = errors.New("invalid field value")
+	// ErrUnsupportedVersion is the error returned when the meta
+	// version of a .torrent file is not supported
+	ErrUnsupportedVersion = errors.New("unsupported meta version")
+	// ErrHybridMismatch is the error returned when the v1 and the v2
+	// descriptions of the content of a hybrid torrent do not match
+	ErrHybridMismatch = errors.New("v1 and v2 content mismatch")
+	// ErrNoAnnounce is the error returned when a private torrent,
+	// that cannot use the DHT, has no tracker to announce to
+	ErrNoAnnounce = errors.New("no announce URL in a private torrent")
+	// ErrUnsafePath is the error returned when the path of a file of
+	// a torrent would escape the directory of the torrent
+	ErrUnsafePath = errors.New("unsafe file path")
+	// ErrInvalidUTF8 is the error returned when a string field of
+	// a torrent cannot be represented as JSON text
+	ErrInvalidUTF8 = errors.New("string is not valid UTF-8")
+	// ErrInvalidPieceLength is the error returned when the piece length
+	// of a Builder is not a power of two between 16 KiB and 16 MiB
+	ErrInvalidPieceLength = errors.New("piece length must be a power of two between 16 KiB and 16 MiB")
+	// ErrPieceLengthOutOfRange is the error returned when the piece
+	// length given to a Hasher is not positive or too large
+	ErrPieceLengthOutOfRange = errors.New("piece length out of range")
+	// ErrNoFiles is the error returned when there are no files
+	// to build a torrent from
+	ErrNoFiles = errors.New("no files to add to the torrent")
+	// ErrFileChanged is the error returned when the size of a
+	// file changes while building a torrent
+	ErrFileChanged = errors.New("file changed while reading")
+)
+
+// ValidationError describes an invalid field of a .torrent file.
+type ValidationError struct {
+	// Key is the path of the invalid field, e.g. "info.files[2].length",
+	// or empty if the top-level value is not a dict
+	Key string
+	Err error
+}
+
+// Error satisfies the error interface.
+func (e *ValidationError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("torrent: invalid top-level value: %v", e.Err)
+	}
+	return fmt.Sprintf("torrent: invalid field %q: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,240 @@
+package torrent
+
+import (
+	"fmt"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+// fieldReader reads the fields of a dict of a .torrent file, keeping
+// track of the keys it consumes and of the first validation error.
+//
+// A key is consumed only if its value is re-emitted when the torrent is
+// encoded again: the other keys, e.g. empty strings and lists, are left
+// in the Extra dict so that they survive a round trip unchanged.
+type fieldReader struct {
+	d    bencode.Dict
+	path string
+	used map[string]bool
+	// err is shared with the readers of the nested dicts
+	err *error
+}
+
+func newFieldReader(d bencode.Dict) *fieldReader {
+	return &fieldReader{
+		d:    d,
+		used: map[string]bool{},
+		err:  new(error),
+	}
+}
+
+// child returns a reader for the nested dict d, at the given path.
+func (r *fieldReader) child(d bencode.Dict, path string) *fieldReader {
+	return &fieldReader{
+		d:    d,
+		path: path,
+		used: map[string]bool{},
+		err:  r.err,
+	}
+}
+
+// keyPath returns the path of the given key.
+func (r *fieldReader) keyPath(key string) string {
+	if r.path == "" {
+		return key
+	}
+	return r.path + "." + key
+}
+
+// fail records a validation error for the given key, unless an
+// error has already been recorded.
+func (r *fieldReader) fail(key string, err error) {
+	if *r.err == nil {
+		*r.err = &ValidationError{Key: key, Err: err}
+	}
+}
+
+// wrongType records a validation error for the given key, holding
+// a value v of a kind different from the expected one.
+func (r *fieldReader) wrongType(key string, expected bencode.Kind, v bencode.Value) {
+	r.fail(key, fmt.Errorf("%w: expected %s, got %s", ErrWrongType, expected, v.Kind()))
+}
+
+// wrongInteger records a validation error for the given key, holding
+// a value v that is not an integer or that does not fit an int64.
+func (r *fieldReader) wrongInteger(key string, v bencode.Value) {
+	if v.Kind() == bencode.IntegerKind {
+		r.fail(key, fmt.Errorf("%w: integer out of range", ErrInvalidValue))
+		return
+	}
+	r.wrongType(key, bencode.IntegerKind, v)
+}
+
+// require records a validation error if any of the keys is missing.
+func (r *fieldReader) require(keys ...string) {
+	for _, k := range keys {
+		if !r.d.Has(k) {
+			r.fail(r.keyPath(k), ErrMissingField)
+		}
+	}
+}
+
+// str returns the value of a bytestring field.
+func (r *fieldReader) str(key string) string {
+	v, ok := r.d.Get(key)
+	if !ok {
+		return ""
+	}
+
+	s, ok := v.AsString()
+	if !ok {
+		r.wrongType(r.keyPath(key), bencode.ByteStringKind, v)
+		return ""
+	}
+	if s != "" {
+		r.used[key] = true
+	}
+
+	return s
+}
+
+// integer returns the value of an integer field.
+func (r *fieldReader) integer(key string) (int64, bool) {
+	v, ok := r.d.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	i, ok := v.AsInt()
+	if !ok {
+		r.wrongInteger(r.keyPath(key), v)
+		return 0, false
+	}
+	r.used[key] = true
+
+	return i, true
+}
+
+// length returns the value of an integer field holding a length,
+// that cannot be negative.
+func (r *fieldReader) length(key string) (int64, bool) {
+	length, ok := r.integer(key)
+	if ok && length < 0 {
+		r.fail(r.keyPath(key), fmt.Errorf("%w: expected a non-negative length, got %d", ErrInvalidValue, length))
+		return 0, false
+	}
+
+	return length, ok
+}
+
+// flag returns true if the value of an integer field is 1.
+func (r *fieldReader) flag(key string) bool {
+	v, ok := r.d.Get(key)
+	if !ok {
+		return false
+	}
+
+	i, ok := v.AsInt()
+	if !ok {
+		r.wrongInteger(r.keyPath(key), v)
+		return false
+	}
+	if i == 1 {
+		r.used[key] = true
+	}
+
+	return i == 1
+}
+
+// hash copies the value of a bytestring field into h, that must
+// have the same length, and returns false if the field is missing.
+func (r *fieldReader) hash(key string, h []byte) bool {
+	if !r.d.Has(key) {
+		return false
+	}
+
+	s := r.str(key)
+	if len(s) != len(h) {
+		r.fail(r.keyPath(key), fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidValue, len(h), len(s)))
+		return false
+	}
+	copy(h, s)
+
+	return true
+}
+
+// list returns the value of a list field.
+func (r *fieldReader) list(key string) bencode.List {
+	v, ok := r.d.Get(key)
+	if !ok {
+		return bencode.List{}
+	}
+
+	l, ok := v.AsList()
+	if !ok {
+		r.wrongType(r.keyPath(key), bencode.ListKind, v)
+		return bencode.List{}
+	}
+	if l.Len() > 0 {
+		r.used[key] = true
+	}
+
+	return l
+}
+
+// strings returns the value of a list of bytestrings field.
+func (r *fieldReader) strings(key string) []string {
+	l := r.list(key)
+	if l.Len() == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, l.Len())
+	for i := 0; i < l.Len(); i++ {
+		v, _ := l.Index(i)
+		s, ok := v.AsString()
+		if !ok {
+			r.wrongType(fmt.Sprintf("%s[%d]", r.keyPath(key), i), bencode.ByteStringKind, v)
+			return nil
+		}
+		values = append(values, s)
+	}
+
+	return values
+}
+
+// dict returns the value of a dict field.
+func (r *fieldReader) dict(key string) (bencode.Dict, bool) {
+	v, ok := r.d.Get(key)
+	if !ok {
+		return bencode.Dict{}, false
+	}
+
+	d, ok := v.AsDict()
+	if !ok {
+		r.wrongType(r.keyPath(key), bencode.DictKind, v)
+		return bencode.Dict{}, false
+	}
+	if d.Len() > 0 {
+		r.used[key] = true
+	}
+
+	return d, true
+}
+
+// extra returns the keys of the dict not consumed by the reader,
+// or nil if there are none.
+func (r *fieldReader) extra() *bencode.Dict {
+	var extra bencode.Dict
+	for _, k := range r.d.Keys() {
+		if !r.used[k] {
+			v, _ := r.d.Get(k)
+			extra.Set(k, v)
+		}
+	}
+	if extra.Len() == 0 {
+		return nil
+	}
+
+	return &extra
+}
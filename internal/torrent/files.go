@@ -0,0 +1,174 @@
+package torrent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+// File holds the description of a file of a multi-file torrent.
+type File struct {
+	Length int64 `json:"length"`
+	// Path holds the components of the path of the file,
+	// relative to the directory named by Info.Name
+	Path   []string `json:"path"`
+	MD5Sum string   `json:"md5sum,omitempty"`
+	// Attr holds the attributes of the file, as defined in BEP 47
+	Attr string `json:"attr,omitempty"`
+	// Extra holds the keys of the file dict not recognized by File
+	Extra *bencode.Dict `json:"extra,omitempty"`
+}
+
+// newFiles returns the files described by the list field key of
+// the info dict read by r.
+func newFiles(r *fieldReader, key string) []File {
+	l := r.list(key)
+	if l.Len() == 0 {
+		return nil
+	}
+
+	files := make([]File, 0, l.Len())
+	for i := 0; i < l.Len(); i++ {
+		path := fmt.Sprintf("%s[%d]", r.keyPath(key), i)
+
+		v, _ := l.Index(i)
+		d, ok := v.AsDict()
+		if !ok {
+			r.wrongType(path, bencode.DictKind, v)
+			return nil
+		}
+
+		fr := r.child(d, path)
+		fr.require("length", "path")
+		f := File{
+			Path:   fr.strings("path"),
+			MD5Sum: fr.str("md5sum"),
+			Attr:   fr.str("attr"),
+		}
+		f.Length, _ = fr.length("length")
+		f.Extra = fr.extra()
+
+		files = append(files, f)
+	}
+
+	return files
+}
+
+// filesToList returns the bencode List representation of files.
+func filesToList(files []File) bencode.List {
+	l := make([]bencode.Value, 0, len(files))
+	for _, f := range files {
+		d := withExtra(f.Extra)
+		d.Set("length", bencode.NewInteger(f.Length))
+		if len(f.Path) > 0 {
+			d.Set("path", stringsToList(f.Path))
+		}
+		if f.MD5Sum != "" {
+			d.Set("md5sum", bencode.NewByteString(f.MD5Sum))
+		}
+		if f.Attr != "" {
+			d.Set("attr", bencode.NewByteString(f.Attr))
+		}
+
+		l = append(l, d)
+	}
+
+	return bencode.NewList(l)
+}
+
+// IsPadding reports whether the file is a padding file, that aligns
+// the following file to a piece boundary and is not part of the content
+// of the torrent, as defined in BEP 47.
+func (f *File) IsPadding() bool {
+	return strings.ContainsRune(f.Attr, 'p')
+}
+
+// IsV1 reports whether the torrent holds the v1 description of its
+// content, as is the case for every torrent that is not a v2 one and
+// for hybrid torrents.
+func (i *Info) IsV1() bool {
+	return !i.IsV2() || i.Pieces != nil
+}
+
+// IsV2 reports whether the torrent holds the v2 description of its
+// content, as defined in BEP 52.
+func (i *Info) IsV2() bool {
+	return i.MetaVersion == 2
+}
+
+// IsHybrid reports whether the torrent holds both the v1 and the v2
+// description of its content.
+func (i *Info) IsHybrid() bool {
+	return i.IsV1() && i.IsV2()
+}
+
+// IsMultiFile reports whether the torrent describes a directory
+// of files instead of a single file.
+func (i *Info) IsMultiFile() bool {
+	if i.IsV1() {
+		return i.Files != nil
+	}
+
+	// a single-file v2 torrent holds just the file at the root of the tree
+	if len(i.FileTree) != 1 {
+		return true
+	}
+	for _, node := range i.FileTree {
+		return node.File == nil || node.Children != nil
+	}
+
+	return true
+}
+
+// TotalLength returns the total size in bytes of the content
+// of the torrent, padding files excluded.
+func (i *Info) TotalLength() int64 {
+	var total int64
+
+	switch {
+	case !i.IsV1():
+		i.FileTree.Walk(func(_ []string, f *TreeFile) {
+			total += f.Length
+		})
+	case i.IsMultiFile():
+		for _, f := range i.Files {
+			if !f.IsPadding() {
+				total += f.Length
+			}
+		}
+	default:
+		total = i.Length
+	}
+
+	return total
+}
+
+// Paths returns the paths of the files of the torrent, relative to the
+// download directory and with "/" separated components. The path of a
+// single-file torrent is its name. Padding files are excluded.
+//
+// The path components are not sanitized: they must be checked before
+// being used to access the file system.
+func (i *Info) Paths() []string {
+	if !i.IsMultiFile() {
+		return []string{i.Name}
+	}
+
+	var paths []string
+	if !i.IsV1() {
+		i.FileTree.Walk(func(path []string, _ *TreeFile) {
+			paths = append(paths, strings.Join(append([]string{i.Name}, path...), "/"))
+		})
+		return paths
+	}
+
+	for _, f := range i.Files {
+		if f.IsPadding() {
+			continue
+		}
+		paths = append(paths, strings.Join(append([]string{i.Name}, f.Path...), "/"))
+	}
+
+	return paths
+}
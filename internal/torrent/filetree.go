@@ -0,0 +1,255 @@
+package torrent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+// PiecesRoot is the root hash of the merkle tree of the pieces of a
+// file of a v2 torrent.
+type PiecesRoot [sha256.Size]byte
+
+// MarshalText satisfies the encoding.TextMarshaler interface
+// to marshal the hash in hexadecimal.
+func (h PiecesRoot) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h[:])), nil
+}
+
+// UnmarshalText satisfies the encoding.TextUnmarshaler interface
+// to unmarshal the hash from hexadecimal.
+func (h *PiecesRoot) UnmarshalText(text []byte) error {
+	buf, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	if len(buf) != len(h) {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidValue, len(h), len(buf))
+	}
+	copy(h[:], buf)
+
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (h PiecesRoot) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// FileTree is the "file tree" of a v2 torrent: it maps the name of each
+// entry of a directory to the entry itself.
+type FileTree map[string]FileTreeNode
+
+// FileTreeNode is an entry of a FileTree, either a file or a directory.
+//
+// Its JSON representation mirrors the bencode one, where a file is a
+// dict with the single key "", holding the description of the file.
+type FileTreeNode struct {
+	// File describes the file, if the entry is a file
+	File *TreeFile
+	// Children are the entries of the directory, if the entry is one
+	Children FileTree
+}
+
+// TreeFile holds the description of a file of a v2 torrent.
+type TreeFile struct {
+	Length int64 `json:"length"`
+	// PiecesRoot is missing for empty files
+	PiecesRoot *PiecesRoot `json:"pieces root,omitempty"`
+	// Extra holds the keys of the file dict not recognized by TreeFile
+	Extra *bencode.Dict `json:"extra,omitempty"`
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (n FileTreeNode) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(n.Children)+1)
+	for name, child := range n.Children {
+		m[name] = child
+	}
+	if n.File != nil {
+		m[""] = n.File
+	}
+
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (n *FileTreeNode) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	*n = FileTreeNode{}
+	for name, raw := range m {
+		if name == "" {
+			n.File = &TreeFile{}
+			if err := json.Unmarshal(raw, n.File); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var child FileTreeNode
+		if err := json.Unmarshal(raw, &child); err != nil {
+			return err
+		}
+		if n.Children == nil {
+			n.Children = FileTree{}
+		}
+		n.Children[name] = child
+	}
+
+	return nil
+}
+
+// Walk calls fn for each file of the tree, in the order of the
+// encoded tree, with the components of the path of the file.
+func (ft FileTree) Walk(fn func(path []string, f *TreeFile)) {
+	ft.walk(nil, fn)
+}
+
+func (ft FileTree) walk(dir []string, fn func(path []string, f *TreeFile)) {
+	names := make([]string, 0, len(ft))
+	for name := range ft {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		node := ft[name]
+		path := append(dir[:len(dir):len(dir)], name)
+		if node.File != nil {
+			fn(path, node.File)
+		}
+		node.Children.walk(path, fn)
+	}
+}
+
+// newFileTree returns the file tree held by the dict field key of the
+// dict read by r.
+func newFileTree(r *fieldReader, key string) FileTree {
+	d, ok := r.dict(key)
+	if !ok || d.Len() == 0 {
+		return nil
+	}
+
+	return newFileTreeEntries(r.child(d, r.keyPath(key)))
+}
+
+// newFileTreeEntries returns the entries of the directory read by r,
+// skipping the keys already consumed.
+func newFileTreeEntries(r *fieldReader) FileTree {
+	var tree FileTree
+	for _, name := range r.d.Keys() {
+		if r.used[name] {
+			continue
+		}
+
+		d, ok := r.dict(name)
+		if !ok {
+			return nil
+		}
+		r.used[name] = true
+
+		if tree == nil {
+			tree = FileTree{}
+		}
+		tree[name] = newFileTreeNode(r.child(d, r.keyPath(name)))
+	}
+
+	return tree
+}
+
+// newFileTreeNode returns the entry of a file tree read by r.
+func newFileTreeNode(r *fieldReader) FileTreeNode {
+	var node FileTreeNode
+
+	if r.d.Has("") {
+		d, _ := r.dict("")
+		r.used[""] = true
+
+		fr := r.child(d, r.path)
+		fr.require("length")
+		f := &TreeFile{}
+		f.Length, _ = fr.length("length")
+		if f.Length > 0 {
+			fr.require("pieces root")
+		}
+		var root PiecesRoot
+		if fr.hash("pieces root", root[:]) {
+			f.PiecesRoot = &root
+		}
+		f.Extra = fr.extra()
+
+		node.File = f
+	}
+	node.Children = newFileTreeEntries(r)
+
+	return node
+}
+
+// toDict returns the bencode Dict representation of the tree.
+func (ft FileTree) toDict() bencode.Dict {
+	var d bencode.Dict
+	for name, node := range ft {
+		nd := node.Children.toDict()
+		if node.File != nil {
+			fd := withExtra(node.File.Extra)
+			fd.Set("length", bencode.NewInteger(node.File.Length))
+			if node.File.PiecesRoot != nil {
+				fd.Set("pieces root", bencode.NewByteString(string(node.File.PiecesRoot[:])))
+			}
+			nd.Set("", fd)
+		}
+		d.Set(name, nd)
+	}
+
+	return d
+}
+
+// newPieceLayers returns the piece layers held by the dict field key
+// of the dict read by r.
+func newPieceLayers(r *fieldReader, key string) map[PiecesRoot][]byte {
+	d, ok := r.dict(key)
+	if !ok || d.Len() == 0 {
+		return nil
+	}
+
+	lr := r.child(d, r.keyPath(key))
+	layers := make(map[PiecesRoot][]byte, d.Len())
+	for _, k := range d.Keys() {
+		var root PiecesRoot
+		if len(k) != len(root) {
+			lr.fail(lr.keyPath(hex.EncodeToString([]byte(k))),
+				fmt.Errorf("%w: expected %d bytes key, got %d", ErrInvalidValue, len(root), len(k)))
+			return nil
+		}
+		copy(root[:], k)
+
+		v, _ := d.Get(k)
+		layer, ok := v.AsString()
+		if !ok {
+			lr.wrongType(lr.keyPath(root.String()), bencode.ByteStringKind, v)
+			return nil
+		}
+		layers[root] = []byte(layer)
+	}
+
+	return layers
+}
+
+// pieceLayersToDict returns the bencode Dict representation
+// of the piece layers.
+func pieceLayersToDict(layers map[PiecesRoot][]byte) bencode.Dict {
+	var d bencode.Dict
+	for root, layer := range layers {
+		d.Set(string(root[:]), bencode.NewByteString(string(layer)))
+	}
+
+	return d
+}
@@ -0,0 +1,173 @@
+package torrent
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// MaxHashPieceLength is the largest piece length accepted by a Hasher,
+// that holds several pieces in memory at once.
+const MaxHashPieceLength = 256 << 20
+
+// MaxHashMemory is the largest amount of memory a Hasher uses for the
+// pieces it holds at once.
+const MaxHashMemory = 1 << 30
+
+// Hasher computes the SHA-1 hashes of the pieces of the content of
+// a torrent. The content is read sequentially by a single goroutine,
+// while the pieces are hashed by a pool of worker goroutines.
+//
+// A Hasher holds one piece for each worker plus the one being read,
+// and uses fewer workers than requested if those pieces would take more
+// than MaxHashMemory bytes, so that the piece length of an untrusted
+// torrent cannot drive the memory usage.
+//
+// The zero value is ready to use.
+type Hasher struct {
+	// Workers is the number of goroutines hashing the pieces;
+	// if 0, it is runtime.GOMAXPROCS(0)
+	Workers int
+	// Progress, if not nil, is called after each piece is hashed, with
+	// the number of pieces and of bytes hashed so far. The calls are
+	// not concurrent, but the pieces can be hashed out of order.
+	Progress func(pieces int, bytes int64)
+}
+
+// piece is a piece of the content to hash.
+type piece struct {
+	index int
+	buf   []byte
+	n     int
+	hash  [sha1.Size]byte
+}
+
+// workers returns the number of worker goroutines hashing pieces of
+// pieceLength bytes, keeping the buffers within MaxHashMemory.
+func (h *Hasher) workers(pieceLength int64) int {
+	workers := h.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// at least one worker, since MaxHashPieceLength fits the budget twice
+	if limit := MaxHashMemory/pieceLength - 1; int64(workers) > limit {
+		workers = int(limit)
+	}
+
+	return workers
+}
+
+// Hash reads r until EOF and returns the concatenated SHA-1 hashes of
+// its pieces of pieceLength bytes, the last one possibly shorter.
+//
+// Hash stops as soon as ctx is done, returning ctx.Err(). At most
+// workers+1 pieces, and no more than MaxHashMemory bytes, are held in
+// memory at any time.
+//
+// Hash returns an error wrapping ErrPieceLengthOutOfRange if pieceLength
+// is not positive or is greater than MaxHashPieceLength.
+func (h *Hasher) Hash(ctx context.Context, r io.Reader, pieceLength int64) ([]byte, error) {
+	if pieceLength <= 0 || pieceLength > MaxHashPieceLength {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPieceLengthOutOfRange, pieceLength)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := h.workers(pieceLength)
+	// free holds the buffers ready to be filled with a piece
+	free := make(chan []byte, workers+1)
+	for i := 0; i < cap(free); i++ {
+		free <- nil
+	}
+	toHash := make(chan *piece, workers)
+	hashed := make(chan *piece, workers)
+
+	var readErr error
+	go func() {
+		defer close(toHash)
+		readErr = h.read(ctx, r, pieceLength, free, toHash)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for p := range toHash {
+				p.hash = sha1.Sum(p.buf[:p.n])
+				hashed <- p
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(hashed)
+	}()
+
+	var (
+		pieces []byte
+		count  int
+		bytes  int64
+	)
+	for p := range hashed {
+		if end := (p.index + 1) * sha1.Size; end > len(pieces) {
+			pieces = append(pieces, make([]byte, end-len(pieces))...)
+		}
+		copy(pieces[p.index*sha1.Size:], p.hash[:])
+		free <- p.buf
+
+		count++
+		bytes += int64(p.n)
+		if h.Progress != nil {
+			h.Progress(count, bytes)
+		}
+	}
+
+	// the reader is done once hashed is closed
+	if readErr != nil {
+		return nil, readErr
+	}
+	if pieces == nil {
+		pieces = []byte{}
+	}
+
+	return pieces, nil
+}
+
+// read reads the pieces of r into the buffers from free, sending them
+// to toHash, until EOF or until ctx is done.
+func (h *Hasher) read(ctx context.Context, r io.Reader, pieceLength int64, free chan []byte, toHash chan<- *piece) error {
+	for index := 0; ; index++ {
+		var buf []byte
+		select {
+		case buf = <-free:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if buf == nil {
+			buf = make([]byte, pieceLength)
+		}
+
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case toHash <- &piece{index: index, buf: buf, n: n}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+var hasherTestCases = []struct {
+	name        string
+	size        int
+	pieceLength int64
+	workers     int
+}{
+	{"empty", 0, 16, 2},
+	{"single short piece", 10, 16, 2},
+	{"exact pieces", 64, 16, 3},
+	{"short last piece", 70, 16, 4},
+	{"one worker", 1000, 16, 1},
+	{"default workers", 1000, 16, 0},
+}
+
+func TestHasher(t *testing.T) {
+	for _, tc := range hasherTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := make([]byte, tc.size)
+			rand.Read(data)
+
+			var (
+				progressPieces int
+				progressBytes  int64
+			)
+			h := Hasher{
+				Workers: tc.workers,
+				Progress: func(pieces int, bytes int64) {
+					progressPieces, progressBytes = pieces, bytes
+				},
+			}
+			got, err := h.Hash(context.Background(), bytes.NewReader(data), tc.pieceLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := piecesOf(data, int(tc.pieceLength))
+			if !bytes.Equal(got, expected) {
+				t.Fatalf("expected %x got %x\n", expected, got)
+			}
+			if progressPieces != len(expected)/20 || progressBytes != int64(tc.size) {
+				t.Fatalf("expected %d pieces and %d bytes got %d and %d\n",
+					len(expected)/20, tc.size, progressPieces, progressBytes)
+			}
+		})
+	}
+}
+
+func TestHasherCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// an endless input stops only when the context is canceled
+	h := Hasher{
+		Workers: 2,
+		Progress: func(pieces int, _ int64) {
+			if pieces == 10 {
+				cancel()
+			}
+		},
+	}
+	_, err := h.Hash(ctx, zeroReader{}, 16)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v got %v\n", context.Canceled, err)
+	}
+}
+
+func TestHasherReadError(t *testing.T) {
+	readErr := errors.New("read error")
+	r := io.MultiReader(bytes.NewReader(make([]byte, 100)), &errReader{readErr})
+
+	var h Hasher
+	if _, err := h.Hash(context.Background(), r, 16); !errors.Is(err, readErr) {
+		t.Fatalf("expected %v got %v\n", readErr, err)
+	}
+}
+
+var hasherPieceLengthTestCases = []struct {
+	name        string
+	pieceLength int64
+}{
+	{"zero", 0},
+	{"negative", -1},
+	{"too large", MaxHashPieceLength + 1},
+}
+
+func TestHasherPieceLength(t *testing.T) {
+	for _, tc := range hasherPieceLengthTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var h Hasher
+			_, err := h.Hash(context.Background(), bytes.NewReader(make([]byte, 100)), tc.pieceLength)
+			if !errors.Is(err, ErrPieceLengthOutOfRange) {
+				t.Fatalf("expected %v got %v\n", ErrPieceLengthOutOfRange, err)
+			}
+		})
+	}
+}
+
+var hasherWorkersTestCases = []struct {
+	name        string
+	workers     int
+	pieceLength int64
+	expected    int
+}{
+	{"small pieces", 64, 256 << 10, 64},
+	{"large pieces", 64, 64 << 20, 15},
+	{"largest pieces", 64, MaxHashPieceLength, 3},
+	{"single worker", 1, MaxHashPieceLength, 1},
+}
+
+func TestHasherWorkers(t *testing.T) {
+	for _, tc := range hasherWorkersTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := Hasher{Workers: tc.workers}
+			got := h.workers(tc.pieceLength)
+			if got != tc.expected {
+				t.Fatalf("expected %d got %d\n", tc.expected, got)
+			}
+			if mem := int64(got+1) * tc.pieceLength; mem > MaxHashMemory {
+				t.Fatalf("buffers take %d bytes, more than %d\n", mem, MaxHashMemory)
+			}
+		})
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// BenchmarkHasher measures the hashing throughput, that scales with
+// the number of workers: run it with -cpu 1,2,4,8 to compare.
+func BenchmarkHasher(b *testing.B) {
+	data := make([]byte, 64<<20)
+	rand.Read(data)
+
+	var h Hasher
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := h.Hash(context.Background(), bytes.NewReader(data), 256<<10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
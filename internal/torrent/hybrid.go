@@ -0,0 +1,67 @@
+package torrent
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// contentFile is a file of the content of a torrent, as described
+// either by the v1 or by the v2 fields.
+type contentFile struct {
+	// key is the path of the field describing the file
+	key    string
+	path   []string
+	length int64
+}
+
+// checkHybrid records a validation error if the v1 and the v2
+// descriptions of the content of the hybrid torrent info, read by r,
+// do not match.
+//
+// The v1 files must be the same as the files of the tree, in the same
+// order, with every non-empty file starting at a piece boundary, thanks
+// to the padding files in between.
+func checkHybrid(r *fieldReader, info *Info) {
+	var v1 []contentFile
+	if info.Files == nil {
+		v1 = append(v1, contentFile{r.keyPath("length"), []string{info.Name}, info.Length})
+	}
+
+	var offset int64
+	for i, f := range info.Files {
+		key := fmt.Sprintf("%s[%d]", r.keyPath("files"), i)
+		if f.IsPadding() {
+			offset += f.Length
+			continue
+		}
+		if f.Length > 0 && info.PieceLength > 0 && offset%info.PieceLength != 0 {
+			r.fail(key, fmt.Errorf("%w: file not aligned to a piece boundary", ErrHybridMismatch))
+			return
+		}
+		offset += f.Length
+
+		v1 = append(v1, contentFile{key, f.Path, f.Length})
+	}
+
+	var v2 []contentFile
+	info.FileTree.Walk(func(path []string, f *TreeFile) {
+		v2 = append(v2, contentFile{path: path, length: f.Length})
+	})
+
+	if len(v1) != len(v2) {
+		r.fail(r.keyPath("file tree"), fmt.Errorf("%w: %d files in v1, %d in v2", ErrHybridMismatch, len(v1), len(v2)))
+		return
+	}
+	for i := range v1 {
+		if !reflect.DeepEqual(v1[i].path, v2[i].path) || v1[i].length != v2[i].length {
+			r.fail(v1[i].key, fmt.Errorf(
+				"%w: file %q of length %d in v1, %q of length %d in v2",
+				ErrHybridMismatch,
+				strings.Join(v1[i].path, "/"), v1[i].length,
+				strings.Join(v2[i].path, "/"), v2[i].length,
+			))
+			return
+		}
+	}
+}
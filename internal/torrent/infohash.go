@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+// InfoHash is the SHA-1 hash of the bencoded info dict of a torrent,
+// that identifies the torrent in the BitTorrent protocol (BTIH).
+type InfoHash [sha1.Size]byte
+
+// Hex returns the lowercase hexadecimal representation of the hash.
+func (h InfoHash) Hex() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Base32 returns the base32 representation of the hash,
+// as used in some magnet links.
+func (h InfoHash) Base32() string {
+	return base32.StdEncoding.EncodeToString(h[:])
+}
+
+// String satisfies the fmt.Stringer interface.
+func (h InfoHash) String() string {
+	return h.Hex()
+}
+
+// InfoHashV2 is the SHA-256 hash of the bencoded info dict of a v2
+// torrent, as defined in BEP 52.
+type InfoHashV2 [sha256.Size]byte
+
+// Hex returns the lowercase hexadecimal representation of the hash.
+func (h InfoHashV2) Hex() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Base32 returns the base32 representation of the hash.
+func (h InfoHashV2) Base32() string {
+	return base32.StdEncoding.EncodeToString(h[:])
+}
+
+// String satisfies the fmt.Stringer interface.
+func (h InfoHashV2) String() string {
+	return h.Hex()
+}
+
+// Truncated returns the hash truncated to the size of a v1 info-hash,
+// that identifies a v2 torrent where a 20 bytes hash is expected, e.g.
+// in the peer wire protocol and in the DHT.
+func (h InfoHashV2) Truncated() InfoHash {
+	var t InfoHash
+	copy(t[:], h[:])
+
+	return t
+}
+
+// InfoHash returns the v1 info-hash of the torrent.
+//
+// For a Torrent returned by NewTorrent, the hash is computed on the exact
+// bytes of the original info dict, even if they are not in canonical form,
+// and changes to the Info field are not reflected in the result. Otherwise
+// the hash is computed on the encoding of Info.
+func (t *Torrent) InfoHash() InfoHash {
+	return sha1.Sum(t.infoBytes())
+}
+
+// InfoHashV2 returns the v2 info-hash of the torrent, computed
+// like InfoHash.
+func (t *Torrent) InfoHashV2() InfoHashV2 {
+	return sha256.Sum256(t.infoBytes())
+}
+
+// InfoHashes returns the 20 bytes info-hashes identifying the torrent:
+// the v1 info-hash for a v1 torrent, the truncated v2 info-hash for a
+// v2 torrent and both, in this order, for a hybrid torrent.
+func (t *Torrent) InfoHashes() []InfoHash {
+	var hashes []InfoHash
+	if t.Info.IsV1() {
+		hashes = append(hashes, t.InfoHash())
+	}
+	if t.Info.IsV2() {
+		hashes = append(hashes, t.InfoHashV2().Truncated())
+	}
+
+	return hashes
+}
+
+// infoBytes returns the original encoding of the info dict, if known,
+// or the encoding of Info.
+func (t *Torrent) infoBytes() []byte {
+	if len(t.rawInfo) > 0 {
+		return t.rawInfo
+	}
+
+	// the encoding of a Dict holding only valid values cannot fail
+	info, _ := t.ToDict().Get("info")
+	data, _ := bencode.Marshal(info)
+
+	return data
+}
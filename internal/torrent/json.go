@@ -0,0 +1,103 @@
+package torrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// jsonTorrent has the fields of Torrent without its methods, to marshal
+// a Torrent with the default encoding.
+type jsonTorrent Torrent
+
+// MarshalJSON satisfies the json.Marshaler interface.
+//
+// JSON strings can only hold UTF-8 text, so MarshalJSON returns a
+// *ValidationError wrapping ErrInvalidUTF8 instead of replacing the
+// invalid bytes of a string field, that would change the torrent and
+// its info hash if encoded back. The Extra dicts are not affected,
+// since they use the lossless JSON representation of bencode values.
+func (t Torrent) MarshalJSON() ([]byte, error) {
+	if err := t.checkUTF8(); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonTorrent(t))
+}
+
+// checkUTF8 returns a *ValidationError for the first string field of
+// the torrent that is not valid UTF-8.
+func (t *Torrent) checkUTF8() error {
+	if err := checkString("announce", t.Announce); err != nil {
+		return err
+	}
+	if err := checkString("comment", t.Comment); err != nil {
+		return err
+	}
+	if err := checkStrings("httpseeds", t.HTTPSeeds); err != nil {
+		return err
+	}
+	if err := checkString("info.name", t.Info.Name); err != nil {
+		return err
+	}
+
+	for i, f := range t.Info.Files {
+		key := fmt.Sprintf("info.files[%d]", i)
+		if err := checkStrings(key+".path", f.Path); err != nil {
+			return err
+		}
+		if err := checkString(key+".md5sum", f.MD5Sum); err != nil {
+			return err
+		}
+		if err := checkString(key+".attr", f.Attr); err != nil {
+			return err
+		}
+	}
+
+	return t.Info.FileTree.checkUTF8("info.file tree")
+}
+
+// checkUTF8 returns a *ValidationError for the first name of the tree,
+// at the given path, that is not valid UTF-8.
+func (ft FileTree) checkUTF8(path string) error {
+	names := make([]string, 0, len(ft))
+	for name := range ft {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := path + "." + name
+		if err := checkString(key, name); err != nil {
+			return err
+		}
+		if err := ft[name].Children.checkUTF8(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkString returns a *ValidationError if the value s of the
+// field key is not valid UTF-8.
+func checkString(key, s string) error {
+	if !utf8.ValidString(s) {
+		return &ValidationError{Key: key, Err: ErrInvalidUTF8}
+	}
+
+	return nil
+}
+
+// checkStrings returns a *ValidationError for the first element of
+// the list field key that is not valid UTF-8.
+func checkStrings(key string, l []string) error {
+	for i, s := range l {
+		if err := checkString(fmt.Sprintf("%s[%d]", key, i), s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
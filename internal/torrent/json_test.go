@@ -0,0 +1,81 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var invalidUTF8TestCases = []struct {
+	name  string
+	input string
+	key   string
+}{
+	{
+		"name",
+		"d4:infod6:lengthi10e4:name4:fi\xffe12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"info.name",
+	},
+	{
+		"comment",
+		"d7:comment2:\xfe\xff4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"comment",
+	},
+	{
+		"file path",
+		strings.Replace(multiFileTorrent, "5:b.txt", "5:b.tx\xff", 1),
+		"info.files[1].path[0]",
+	},
+	{
+		"file tree name",
+		strings.Replace(v2Torrent, "3:dir", "3:d\xffr", 1),
+		"info.file tree.d\xffr",
+	},
+}
+
+func TestMarshalJSONInvalidUTF8(t *testing.T) {
+	for _, tc := range invalidUTF8TestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			torrent, err := NewTorrent(bytes.NewReader([]byte(tc.input)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = json.Marshal(torrent)
+			if !errors.Is(err, ErrInvalidUTF8) {
+				t.Fatalf("expected %v got %v\n", ErrInvalidUTF8, err)
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a *ValidationError got %T\n", err)
+			}
+			if verr.Key != tc.key {
+				t.Fatalf("expected %q got %q\n", tc.key, verr.Key)
+			}
+		})
+	}
+}
+
+func TestMarshalJSONExtraBytes(t *testing.T) {
+	input := strings.Replace(extraTorrent, "6:source3:src", "6:source3:\xffrc", 1)
+	torrent, err := NewTorrent(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the Extra dicts hold bencode values, that have a lossless
+	// JSON representation
+	buf, err := json.Marshal(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Torrent
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.InfoHash(); got != torrent.InfoHash() {
+		t.Fatalf("expected %v got %v\n", torrent.InfoHash(), got)
+	}
+}
@@ -1,8 +1,12 @@
 package torrent
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"time"
 
 	"github.com/pippolo84/beetools/pkg/bencode"
@@ -10,81 +14,398 @@ import (
 
 // Info holds the "info" part of a .torrent file.
 type Info struct {
-	Length      int64  `json:"length"`
+	// Length is the size of the file of a single-file torrent
+	Length int64 `json:"length,omitempty"`
+	// Files are the files of a multi-file torrent
+	Files       []File `json:"files,omitempty"`
 	Name        string `json:"name"`
 	PieceLength int64  `json:"piece length"`
-	Pieces      []byte `json:"pieces"`
+	// Pieces holds the SHA-1 hashes of the pieces of a v1 torrent
+	Pieces []byte `json:"pieces,omitempty"`
+	// MetaVersion is 2 for a v2 torrent, as defined in BEP 52
+	MetaVersion int64 `json:"meta version,omitempty"`
+	// FileTree holds the files of a v2 torrent
+	FileTree FileTree `json:"file tree,omitempty"`
+	// Private reports whether peers must be obtained only from the
+	// trackers of the torrent, as defined in BEP 27
+	Private bool `json:"private,omitempty"`
+	// Extra holds the keys of the info dict not recognized by Info
+	Extra *bencode.Dict `json:"extra,omitempty"`
 }
 
 // Torrent represents all the information in a .torrent file.
 type Torrent struct {
-	Announce     string    `json:"announce"`
-	Comment      string    `json:"comment"`
+	Announce     string    `json:"announce,omitempty"`
+	Comment      string    `json:"comment,omitempty"`
 	CreationDate time.Time `json:"creation date"`
-	HTTPSeeds    []string  `json:"httpseeds"`
+	HTTPSeeds    []string  `json:"httpseeds,omitempty"`
 	Info         Info      `json:"info"`
+	// PieceLayers holds the hashes of the pieces of each file of a
+	// v2 torrent, by the pieces root of the file
+	PieceLayers map[PiecesRoot][]byte `json:"piece layers,omitempty"`
+	// Extra holds the top-level keys not recognized by Torrent
+	Extra *bencode.Dict `json:"extra,omitempty"`
+
+	// rawInfo holds the original encoding of the info dict
+	rawInfo bencode.RawMessage
 }
 
 // NewTorrent returns a new Torrent initialized with bencode-data from
 // read the r io.Reader.
+//
+// If the data is not a valid .torrent file, NewTorrent returns a
+// *ValidationError describing the first invalid field found.
 func NewTorrent(r io.Reader) (*Torrent, error) {
-	d := bencode.Dict{}
-	dec := bencode.NewDecoder(r)
-	if err := dec.Decode(&d); err != nil {
+	d, rawInfo, err := decodeTorrent(r)
+	if err != nil {
 		return nil, err
 	}
 
-	mapValue := d.Value()
+	t, err := newTorrent(d)
+	if err != nil {
+		return nil, err
+	}
+	t.rawInfo = rawInfo
+
+	return t, nil
+}
+
+// decodeTorrent reads the top-level dict of a .torrent file from r in a
+// single pass, keeping integers of any size and the original encoding
+// of the info dict.
+func decodeTorrent(r io.Reader) (bencode.Dict, bencode.RawMessage, error) {
+	dec := bencode.NewDecoder(r)
+	dec.UseBigInt()
+
+	d := bencode.Dict{}
+	tok, err := dec.Token()
+	if err != nil {
+		return d, nil, err
+	}
+	if tok != bencode.Delim(bencode.DictStart) {
+		kind := bencode.ListKind
+		if v, ok := tok.(bencode.Value); ok {
+			kind = v.Kind()
+		}
+		return d, nil, &ValidationError{Err: fmt.Errorf("%w: expected %s, got %s", ErrWrongType, bencode.DictKind, kind)}
+	}
+
+	var rawInfo bencode.RawMessage
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return d, nil, err
+		}
+		key := tok.(bencode.ByteString).Value()
+
+		var v bencode.Value
+		if key == "info" {
+			if err := dec.Decode(&rawInfo); err != nil {
+				return d, nil, err
+			}
+			v, err = decodeInfo(rawInfo)
+		} else {
+			err = dec.Decode(&v)
+		}
+		if err != nil {
+			return d, nil, err
+		}
+		d.Set(key, v)
+	}
+
+	// consume the end of the dict
+	if _, err := dec.Token(); err != nil {
+		return d, nil, err
+	}
+
+	return d, rawInfo, nil
+}
+
+// decodeInfo decodes the original encoding of the info dict.
+func decodeInfo(data bencode.RawMessage) (bencode.Value, error) {
+	dec := bencode.NewDecoder(bytes.NewReader(data))
+	dec.UseBigInt()
+
+	var v bencode.Value
+	err := dec.Decode(&v)
+
+	return v, err
+}
+
+// newTorrent returns the Torrent described by the top-level dict d.
+func newTorrent(d bencode.Dict) (*Torrent, error) {
+	r := newFieldReader(d)
+
+	r.require("info")
+	infoDict, _ := r.dict("info")
+	t := &Torrent{
+		Announce:  r.str("announce"),
+		Comment:   r.str("comment"),
+		HTTPSeeds: r.strings("httpseeds"),
+		Info:      newInfo(r.child(infoDict, "info")),
+	}
+	if d.Has("piece layers") {
+		t.PieceLayers = newPieceLayers(r, "piece layers")
+	}
+	if date, ok := r.integer("creation date"); ok {
+		t.CreationDate = time.Unix(date, 0)
+	}
+	t.Extra = r.extra()
+
+	if *r.err != nil {
+		return nil, *r.err
+	}
+
+	// a private torrent cannot find peers through the DHT, so it
+	// needs at least a tracker
+	if t.Info.Private && t.Announce == "" && !hasAnnounceList(t.Extra) {
+		return nil, &ValidationError{Key: "announce", Err: ErrNoAnnounce}
+	}
+
+	return t, nil
+}
+
+// newInfo returns the Info described by the info dict read by r.
+func newInfo(r *fieldReader) Info {
+	r.require("name", "piece length")
 
-	infoValue := mapValue["info"].(map[string]interface{})
 	info := Info{
-		Length:      infoValue["length"].(int64),
-		Name:        infoValue["name"].(string),
-		PieceLength: infoValue["piece length"].(int64),
-		Pieces:      []byte(infoValue["pieces"].(string)),
+		Name:    r.str("name"),
+		Private: r.flag("private"),
+	}
+	if length, ok := r.integer("piece length"); ok {
+		if length <= 0 {
+			r.fail(r.keyPath("piece length"), fmt.Errorf("%w: expected a positive length, got %d", ErrInvalidValue, length))
+		}
+		info.PieceLength = length
+	}
+	info.MetaVersion, _ = r.integer("meta version")
+
+	v2 := r.d.Has("meta version")
+	if v2 {
+		if info.MetaVersion != 2 {
+			r.fail(r.keyPath("meta version"), fmt.Errorf("%w %d", ErrUnsupportedVersion, info.MetaVersion))
+		}
+		r.require("file tree")
+		info.FileTree = newFileTree(r, "file tree")
+	}
+
+	// a v2 torrent holds the v1 fields too only if it is a hybrid one
+	if !v2 || r.d.Has("pieces") || r.d.Has("files") || r.d.Has("length") {
+		r.require("pieces")
+		if pieces := r.str("pieces"); pieces != "" {
+			if len(pieces)%sha1.Size != 0 {
+				r.fail(r.keyPath("pieces"), fmt.Errorf(
+					"%w: expected a multiple of %d bytes, got %d", ErrInvalidValue, sha1.Size, len(pieces)))
+			}
+			info.Pieces = []byte(pieces)
+		}
+		if r.d.Has("files") {
+			info.Files = newFiles(r, "files")
+		}
+		if info.Files == nil {
+			r.require("length")
+			info.Length, _ = r.length("length")
+		}
+	}
+	info.Extra = r.extra()
+
+	if *r.err == nil && info.IsHybrid() {
+		checkHybrid(r, &info)
+	}
+	if *r.err == nil && info.IsV1() {
+		checkPieces(r, &info)
+	}
+
+	return info
+}
+
+// checkPieces records a validation error if the number of v1 piece
+// hashes of info, read by r, does not match the number of pieces of
+// its content, padding files included.
+func checkPieces(r *fieldReader, info *Info) {
+	total := info.Length
+	if info.Files != nil {
+		total = 0
+		for _, f := range info.Files {
+			if total > math.MaxInt64-f.Length {
+				r.fail(r.keyPath("files"), fmt.Errorf("%w: total length out of range", ErrInvalidValue))
+				return
+			}
+			total += f.Length
+		}
+	}
+
+	pieces := total / info.PieceLength
+	if total%info.PieceLength != 0 {
+		pieces++
+	}
+	if got := int64(len(info.Pieces) / sha1.Size); got != pieces {
+		r.fail(r.keyPath("pieces"), fmt.Errorf(
+			"%w: expected %d piece hashes for %d bytes, got %d", ErrInvalidValue, pieces, total, got))
 	}
+}
+
+// hasAnnounceList reports whether extra holds a non-empty
+// "announce-list" key, as defined in BEP 12.
+func hasAnnounceList(extra *bencode.Dict) bool {
+	return len(extraStrings(extra, "announce-list")) > 0
+}
+
+// Trackers returns the URLs of the trackers of the torrent: the announce
+// URL followed by the URLs of the "announce-list" key, as defined in
+// BEP 12, without duplicates.
+func (t *Torrent) Trackers() []string {
+	var trackers []string
+	for _, url := range append([]string{t.Announce}, extraStrings(t.Extra, "announce-list")...) {
+		if url != "" && !contains(trackers, url) {
+			trackers = append(trackers, url)
+		}
+	}
+
+	return trackers
+}
+
+// WebSeeds returns the URLs of the "url-list" key of the torrent,
+// as defined in BEP 19.
+func (t *Torrent) WebSeeds() []string {
+	return extraStrings(t.Extra, "url-list")
+}
 
-	seedsValue := mapValue["httpseeds"].([]interface{})
-	seeds := make([]string, 0, len(seedsValue))
-	for _, v := range seedsValue {
-		seeds = append(seeds, v.(string))
+// extraStrings returns the bytestrings held by the key of extra, either
+// directly or inside lists, ignoring any other value.
+func extraStrings(extra *bencode.Dict, key string) []string {
+	if extra == nil {
+		return nil
 	}
-	return &Torrent{
-		Announce:     mapValue["announce"].(string),
-		Comment:      mapValue["comment"].(string),
-		CreationDate: time.Unix(mapValue["creation date"].(int64), 0),
-		HTTPSeeds:    seeds,
-		Info:         info,
-	}, nil
+
+	v, ok := extra.Get(key)
+	if !ok {
+		return nil
+	}
+
+	return appendStrings(nil, v)
+}
+
+// appendStrings appends to s the bytestrings held by v, either
+// directly or inside lists, ignoring any other value.
+func appendStrings(s []string, v bencode.Value) []string {
+	if str, ok := v.AsString(); ok {
+		return append(s, str)
+	}
+	if l, ok := v.AsList(); ok {
+		for i := 0; i < l.Len(); i++ {
+			elem, _ := l.Index(i)
+			s = appendStrings(s, elem)
+		}
+	}
+
+	return s
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
 
 // ToDict returns a bencode package Dict representation of the torrent.
+// Optional fields holding their zero value are omitted.
 func (t *Torrent) ToDict() bencode.Dict {
-	seedsValues := make([]interface{}, 0, len(t.HTTPSeeds))
-	for _, v := range t.HTTPSeeds {
-		seedsValues = append(seedsValues, bencode.NewByteString(v))
+	info := withExtra(t.Info.Extra)
+	if t.Info.Files != nil {
+		info.Set("files", filesToList(t.Info.Files))
+	} else if t.Info.IsV1() {
+		info.Set("length", bencode.NewInteger(t.Info.Length))
+	}
+	if t.Info.Name != "" {
+		info.Set("name", bencode.NewByteString(t.Info.Name))
+	}
+	if t.Info.PieceLength != 0 {
+		info.Set("piece length", bencode.NewInteger(t.Info.PieceLength))
+	}
+	if t.Info.Pieces != nil {
+		info.Set("pieces", bencode.NewByteString(string(t.Info.Pieces)))
+	}
+	if t.Info.Private {
+		info.Set("private", bencode.NewInteger(1))
+	}
+	if t.Info.MetaVersion != 0 {
+		info.Set("meta version", bencode.NewInteger(t.Info.MetaVersion))
+	}
+	if t.Info.FileTree != nil {
+		info.Set("file tree", t.Info.FileTree.toDict())
 	}
 
-	return bencode.NewDict(map[bencode.ByteString]interface{}{
-		bencode.NewByteString("announce"):      bencode.NewByteString(t.Announce),
-		bencode.NewByteString("comment"):       bencode.NewByteString(t.Comment),
-		bencode.NewByteString("creation date"): bencode.NewInteger(t.CreationDate.Unix()),
-		bencode.NewByteString("httpseeds"):     bencode.NewList(seedsValues),
-		bencode.NewByteString("info"): bencode.NewDict(map[bencode.ByteString]interface{}{
-			bencode.NewByteString("length"):       bencode.NewInteger(t.Info.Length),
-			bencode.NewByteString("name"):         bencode.NewByteString(t.Info.Name),
-			bencode.NewByteString("piece length"): bencode.NewInteger(t.Info.PieceLength),
-			bencode.NewByteString("pieces"):       bencode.NewByteString(string(t.Info.Pieces)),
-		}),
-	})
+	d := withExtra(t.Extra)
+	if t.Announce != "" {
+		d.Set("announce", bencode.NewByteString(t.Announce))
+	}
+	if t.Comment != "" {
+		d.Set("comment", bencode.NewByteString(t.Comment))
+	}
+	if !t.CreationDate.IsZero() {
+		d.Set("creation date", bencode.NewInteger(t.CreationDate.Unix()))
+	}
+	if len(t.HTTPSeeds) > 0 {
+		d.Set("httpseeds", stringsToList(t.HTTPSeeds))
+	}
+	d.Set("info", info)
+	if len(t.PieceLayers) > 0 {
+		d.Set("piece layers", pieceLayersToDict(t.PieceLayers))
+	}
+
+	return d
+}
+
+// stringsToList returns the bencode List representation of values.
+func stringsToList(values []string) bencode.List {
+	l := make([]bencode.Value, 0, len(values))
+	for _, v := range values {
+		l = append(l, bencode.NewByteString(v))
+	}
+
+	return bencode.NewList(l)
+}
+
+// withExtra returns a new Dict holding the keys of extra, if any.
+func withExtra(extra *bencode.Dict) bencode.Dict {
+	var d bencode.Dict
+	if extra == nil {
+		return d
+	}
+
+	for _, k := range extra.Keys() {
+		v, _ := extra.Get(k)
+		d.Set(k, v)
+	}
+
+	return d
 }
 
 // String satisfies the fmt.Stringer interface.
 func (t Torrent) String() string {
+	out := struct {
+		InfoHash   string `json:"info hash,omitempty"`
+		InfoHashV2 string `json:"info hash v2,omitempty"`
+		jsonTorrent
+	}{jsonTorrent: jsonTorrent(t)}
+	if t.Info.IsV1() {
+		out.InfoHash = t.InfoHash().Hex()
+	}
+	if t.Info.IsV2() {
+		out.InfoHashV2 = t.InfoHashV2().Hex()
+	}
+
 	// filter pieces data away for stringification
-	t.Info.Pieces = []byte("")
+	out.Info.Pieces = nil
+	out.PieceLayers = nil
 
-	buf, err := json.MarshalIndent(t, "", "  ")
+	buf, err := json.MarshalIndent(out, "", "  ")
 	if err != nil {
 		return ""
 	}
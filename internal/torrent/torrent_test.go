@@ -0,0 +1,532 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pippolo84/beetools/pkg/bencode"
+)
+
+const extraTorrent = "d8:announce3:url13:announce-listll3:urlee7:comment1:c13:creation datei1612616374e9:httpseedsl4:seede" +
+	"4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:012345678901234567897:privatei1e6:source3:srce" +
+	"8:url-listl3:webee"
+
+func TestTorrentExtraRoundTrip(t *testing.T) {
+	torrent, err := NewTorrent(bytes.NewReader([]byte(extraTorrent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := torrent.Extra.Keys(); len(got) != 2 || got[0] != "announce-list" || got[1] != "url-list" {
+		t.Fatalf("expected %v got %v\n", []string{"announce-list", "url-list"}, got)
+	}
+	if got := torrent.Info.Extra.Keys(); len(got) != 1 || got[0] != "source" {
+		t.Fatalf("expected %v got %v\n", []string{"source"}, got)
+	}
+	if !torrent.Info.Private {
+		t.Fatal("expected a private torrent")
+	}
+
+	// round trip through the JSON representation too
+	buf, err := json.Marshal(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Torrent
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := bencode.Marshal(decoded.ToDict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != extraTorrent {
+		t.Fatalf("expected %q got %q\n", extraTorrent, got)
+	}
+}
+
+const bigIntTorrent = "d8:announce3:url3:bigi123456789012345678901234567890e" +
+	"4:infod4:hugei-98765432109876543210987654321e6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee"
+
+func TestTorrentExtraBigInt(t *testing.T) {
+	torrent, err := NewTorrent(bytes.NewReader([]byte(bigIntTorrent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		extra *bencode.Dict
+		key   string
+		want  string
+	}{
+		{torrent.Extra, "big", "123456789012345678901234567890"},
+		{torrent.Info.Extra, "huge", "-98765432109876543210987654321"},
+	} {
+		v, ok := tc.extra.Get(tc.key)
+		if !ok {
+			t.Fatalf("missing extra key %q\n", tc.key)
+		}
+		i, ok := v.AsBigInt()
+		if !ok || i.String() != tc.want {
+			t.Fatalf("expected %s got %v\n", tc.want, v)
+		}
+	}
+
+	got, err := bencode.Marshal(torrent.ToDict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != bigIntTorrent {
+		t.Fatalf("expected %q got %q\n", bigIntTorrent, got)
+	}
+	info := bigIntTorrent[strings.Index(bigIntTorrent, "4:info")+len("4:info") : len(bigIntTorrent)-1]
+	if want := InfoHash(sha1.Sum([]byte(info))); torrent.InfoHash() != want {
+		t.Fatalf("expected %v got %v\n", want, torrent.InfoHash())
+	}
+}
+
+const multiFileTorrent = "d8:announce3:url7:comment1:c13:creation datei1612616374e9:httpseedsle" +
+	"4:infod5:filesld6:lengthi3e6:md5sum32:0123456789abcdef0123456789abcdef4:pathl3:dir5:a.txteed4:attr1:x6:lengthi5e4:pathl5:b.txteee" +
+	"4:name4:root12:piece lengthi16e6:pieces20:01234567890123456789ee"
+
+func TestMultiFileTorrent(t *testing.T) {
+	torrent, err := NewTorrent(bytes.NewReader([]byte(multiFileTorrent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !torrent.Info.IsMultiFile() {
+		t.Fatal("expected a multi-file torrent")
+	}
+	if got := torrent.Info.TotalLength(); got != 8 {
+		t.Fatalf("expected %d got %d\n", 8, got)
+	}
+	expected := []string{"root/dir/a.txt", "root/b.txt"}
+	if got := torrent.Info.Paths(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+	if got := torrent.Info.Files[0].MD5Sum; got != "0123456789abcdef0123456789abcdef" {
+		t.Fatalf("expected %q got %q\n", "0123456789abcdef0123456789abcdef", got)
+	}
+
+	got, err := bencode.Marshal(torrent.ToDict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != multiFileTorrent {
+		t.Fatalf("expected %q got %q\n", multiFileTorrent, got)
+	}
+}
+
+// hybridPrefix and hybridSuffix enclose the "files" list of a hybrid
+// torrent, holding the v2 file tree and the other info dict keys.
+const (
+	hybridPrefix = "d4:infod9:file treed3:dird5:a.txtd0:d6:lengthi3e11:pieces root32:0123456789abcdef0123456789abcdefeee" +
+		"5:z.txtd0:d6:lengthi5e11:pieces root32:abcdefghijklmnopqrstuvwxyz012345eee"
+	hybridSuffix = "12:meta versioni2e4:name4:root12:piece lengthi16384e6:pieces40:0123456789012345678901234567890123456789ee"
+)
+
+const hybridTorrent = hybridPrefix +
+	"5:filesld6:lengthi3e4:pathl3:dir5:a.txteed4:attr1:p6:lengthi16381e4:pathl4:.pad5:16381eed6:lengthi5e4:pathl5:z.txteee" +
+	hybridSuffix
+
+var newTorrentErrorTestCases = []struct {
+	name  string
+	input string
+	key   string
+	err   error
+}{
+	{
+		"integer top-level value",
+		"i1e",
+		"",
+		ErrWrongType,
+	},
+	{
+		"list top-level value",
+		"li1ee",
+		"",
+		ErrWrongType,
+	},
+	{
+		"missing info",
+		"d8:announce3:urle",
+		"info",
+		ErrMissingField,
+	},
+	{
+		"info wrong type",
+		"d8:announce3:url4:infol4:fileee",
+		"info",
+		ErrWrongType,
+	},
+	{
+		"missing name",
+		"d4:infod6:lengthi10e12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"info.name",
+		ErrMissingField,
+	},
+	{
+		"missing length",
+		"d4:infod4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"info.length",
+		ErrMissingField,
+	},
+	{
+		"piece length wrong type",
+		"d4:infod6:lengthi10e4:name4:file12:piece length2:166:pieces0:ee",
+		"info.piece length",
+		ErrWrongType,
+	},
+	{
+		"zero piece length",
+		"d4:infod6:lengthi10e4:name4:file12:piece lengthi0e6:pieces0:ee",
+		"info.piece length",
+		ErrInvalidValue,
+	},
+	{
+		"negative piece length",
+		"d4:infod6:lengthi10e4:name4:file12:piece lengthi-1e6:pieces0:ee",
+		"info.piece length",
+		ErrInvalidValue,
+	},
+	{
+		"piece length out of range",
+		"d4:infod6:lengthi10e4:name4:file12:piece lengthi99999999999999999999999e6:pieces0:ee",
+		"info.piece length",
+		ErrInvalidValue,
+	},
+	{
+		"private out of range",
+		"d8:announce3:url4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789" +
+			"7:privatei99999999999999999999999eee",
+		"info.private",
+		ErrInvalidValue,
+	},
+	{
+		"truncated pieces",
+		"d4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces19:0123456789012345678ee",
+		"info.pieces",
+		ErrInvalidValue,
+	},
+	{
+		"too few pieces",
+		"d4:infod6:lengthi100000e4:name1:a12:piece lengthi16384e6:pieces20:01234567890123456789ee",
+		"info.pieces",
+		ErrInvalidValue,
+	},
+	{
+		"too many pieces",
+		"d4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces40:0123456789012345678901234567890123456789ee",
+		"info.pieces",
+		ErrInvalidValue,
+	},
+	{
+		"negative length",
+		"d4:infod6:lengthi-10e4:name4:file12:piece lengthi16e6:pieces0:ee",
+		"info.length",
+		ErrInvalidValue,
+	},
+	{
+		"negative file length",
+		"d4:infod5:filesld6:lengthi-3e4:pathl1:aeee4:name4:root12:piece lengthi16e6:pieces0:ee",
+		"info.files[0].length",
+		ErrInvalidValue,
+	},
+	{
+		"multi-file too few pieces",
+		"d4:infod5:filesld6:lengthi10e4:pathl1:aeed6:lengthi10e4:pathl1:beee4:name4:root12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"info.pieces",
+		ErrInvalidValue,
+	},
+	{
+		"httpseeds element wrong type",
+		"d9:httpseedsl4:seedi1ee4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"httpseeds[1]",
+		ErrWrongType,
+	},
+	{
+		"file wrong type",
+		"d4:infod5:filesl4:filee4:name4:root12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"info.files[0]",
+		ErrWrongType,
+	},
+	{
+		"file missing path",
+		"d4:infod5:filesld6:lengthi3e4:pathl1:aeed6:lengthi3eee4:name4:root12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"info.files[1].path",
+		ErrMissingField,
+	},
+	{
+		"v2 missing file tree",
+		"d4:infod12:meta versioni2e4:name4:file12:piece lengthi16384eee",
+		"info.file tree",
+		ErrMissingField,
+	},
+	{
+		"unsupported meta version",
+		"d4:infod9:file treed1:ad0:d6:lengthi0eeee12:meta versioni3e4:name4:file12:piece lengthi16384eee",
+		"info.meta version",
+		ErrUnsupportedVersion,
+	},
+	{
+		"v2 missing pieces root",
+		"d4:infod9:file treed1:ad0:d6:lengthi1eeee12:meta versioni2e4:name4:file12:piece lengthi16384eee",
+		"info.file tree.a.pieces root",
+		ErrMissingField,
+	},
+	{
+		"v2 short pieces root",
+		"d4:infod9:file treed1:ad0:d6:lengthi1e11:pieces root3:abceee12:meta versioni2e4:name4:file12:piece lengthi16384eee",
+		"info.file tree.a.pieces root",
+		ErrInvalidValue,
+	},
+	{
+		"hybrid without padding",
+		hybridPrefix + "5:filesld6:lengthi3e4:pathl3:dir5:a.txteed6:lengthi5e4:pathl5:z.txteee" + hybridSuffix,
+		"info.files[1]",
+		ErrHybridMismatch,
+	},
+	{
+		"hybrid length mismatch",
+		hybridPrefix +
+			"5:filesld6:lengthi3e4:pathl3:dir5:a.txteed4:attr1:p6:lengthi16381e4:pathl4:.pad5:16381eed6:lengthi6e4:pathl5:z.txteee" +
+			hybridSuffix,
+		"info.files[2]",
+		ErrHybridMismatch,
+	},
+	{
+		"hybrid too few pieces",
+		hybridPrefix +
+			"5:filesld6:lengthi3e4:pathl3:dir5:a.txteed4:attr1:p6:lengthi16381e4:pathl4:.pad5:16381eed6:lengthi5e4:pathl5:z.txteee" +
+			"12:meta versioni2e4:name4:root12:piece lengthi16384e6:pieces20:01234567890123456789ee",
+		"info.pieces",
+		ErrInvalidValue,
+	},
+	{
+		"hybrid missing file",
+		hybridPrefix + "5:filesld6:lengthi3e4:pathl3:dir5:a.txteee" + hybridSuffix,
+		"info.file tree",
+		ErrHybridMismatch,
+	},
+	{
+		"private without announce",
+		"d4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:012345678901234567897:privatei1eee",
+		"announce",
+		ErrNoAnnounce,
+	},
+}
+
+func TestNewTorrentErrors(t *testing.T) {
+	for _, tc := range newTorrentErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTorrent(bytes.NewReader([]byte(tc.input)))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v got %v\n", tc.err, err)
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a *ValidationError got %T\n", err)
+			}
+			if verr.Key != tc.key {
+				t.Fatalf("expected %q got %q\n", tc.key, verr.Key)
+			}
+		})
+	}
+}
+
+var optionalFieldsTestCases = []struct {
+	name  string
+	input string
+}{
+	{
+		"no optional fields",
+		"d4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee",
+	},
+	{
+		"trackerless",
+		"d7:comment1:c4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee",
+	},
+	{
+		"private with announce-list",
+		"d13:announce-listll3:urlee4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:012345678901234567897:privatei1eee",
+	},
+	{
+		"empty values",
+		"d8:announce0:9:httpseedsle4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:012345678901234567897:privatei0eee",
+	},
+}
+
+func TestOptionalFields(t *testing.T) {
+	for _, tc := range optionalFieldsTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			torrent, err := NewTorrent(bytes.NewReader([]byte(tc.input)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := bencode.Marshal(torrent.ToDict())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.input {
+				t.Fatalf("expected %q got %q\n", tc.input, got)
+			}
+		})
+	}
+}
+
+var infoHashTestCases = []struct {
+	name  string
+	input string
+	info  string
+}{
+	{
+		"canonical",
+		"d8:announce3:url4:infod6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"d6:lengthi10e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789e",
+	},
+	{
+		"unsorted keys",
+		"d4:infod4:name4:file6:lengthi10e12:piece lengthi16e6:pieces20:01234567890123456789e8:announce3:urle",
+		"d4:name4:file6:lengthi10e12:piece lengthi16e6:pieces20:01234567890123456789e",
+	},
+	{
+		"non-canonical integer",
+		"d4:infod6:lengthi010e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789ee",
+		"d6:lengthi010e4:name4:file12:piece lengthi16e6:pieces20:01234567890123456789e",
+	},
+}
+
+func TestInfoHash(t *testing.T) {
+	for _, tc := range infoHashTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			torrent, err := NewTorrent(bytes.NewReader([]byte(tc.input)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := InfoHash(sha1.Sum([]byte(tc.info)))
+			if got := torrent.InfoHash(); got != expected {
+				t.Fatalf("expected %v got %v\n", expected, got)
+			}
+		})
+	}
+}
+
+func TestInfoHashWithoutOriginal(t *testing.T) {
+	torrent, err := NewTorrent(bytes.NewReader([]byte(extraTorrent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the JSON representation does not hold the original info dict
+	buf, err := json.Marshal(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Torrent
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := decoded.InfoHash(), torrent.InfoHash(); got != expected {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+}
+
+const v2Torrent = "d8:announce3:url4:infod9:file treed5:b.txtd0:d6:lengthi0eee3:dird5:a.txtd0:d4:attr1:x6:lengthi3e" +
+	"11:pieces root32:0123456789abcdef0123456789abcdefeeee" +
+	"12:meta versioni2e4:name4:root12:piece lengthi16384ee" +
+	"12:piece layersd32:0123456789abcdef0123456789abcdef32:abcdefghijklmnopqrstuvwxyz012345ee"
+
+func TestV2Torrent(t *testing.T) {
+	torrent, err := NewTorrent(bytes.NewReader([]byte(v2Torrent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if torrent.Info.IsV1() || !torrent.Info.IsV2() {
+		t.Fatal("expected a v2 only torrent")
+	}
+	if got := torrent.Info.TotalLength(); got != 3 {
+		t.Fatalf("expected %d got %d\n", 3, got)
+	}
+	expected := []string{"root/b.txt", "root/dir/a.txt"}
+	if got := torrent.Info.Paths(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+
+	var root PiecesRoot
+	copy(root[:], "0123456789abcdef0123456789abcdef")
+	if got := torrent.Info.FileTree["dir"].Children["a.txt"].File.PiecesRoot; got == nil || *got != root {
+		t.Fatalf("expected %v got %v\n", root, got)
+	}
+	if got := string(torrent.PieceLayers[root]); got != "abcdefghijklmnopqrstuvwxyz012345" {
+		t.Fatalf("expected %q got %q\n", "abcdefghijklmnopqrstuvwxyz012345", got)
+	}
+
+	info := v2Torrent[bytes.Index([]byte(v2Torrent), []byte("4:infod"))+6 : bytes.Index([]byte(v2Torrent), []byte("12:piece layers"))]
+	if got, expected := torrent.InfoHashV2(), InfoHashV2(sha256.Sum256([]byte(info))); got != expected {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+
+	// round trip through the JSON representation too
+	buf, err := json.Marshal(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Torrent
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := bencode.Marshal(decoded.ToDict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != v2Torrent {
+		t.Fatalf("expected %q got %q\n", v2Torrent, got)
+	}
+}
+
+func TestHybridTorrent(t *testing.T) {
+	torrent, err := NewTorrent(bytes.NewReader([]byte(hybridTorrent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !torrent.Info.IsHybrid() {
+		t.Fatal("expected a hybrid torrent")
+	}
+	if got := torrent.Info.TotalLength(); got != 8 {
+		t.Fatalf("expected %d got %d\n", 8, got)
+	}
+	expected := []string{"root/dir/a.txt", "root/z.txt"}
+	if got := torrent.Info.Paths(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+
+	hashes := []InfoHash{torrent.InfoHash(), torrent.InfoHashV2().Truncated()}
+	if got := torrent.InfoHashes(); !reflect.DeepEqual(got, hashes) {
+		t.Fatalf("expected %v got %v\n", hashes, got)
+	}
+	if hashes[0] == hashes[1] {
+		t.Fatalf("expected different v1 and v2 info-hashes got %v\n", hashes[0])
+	}
+
+	got, err := bencode.Marshal(torrent.ToDict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != hybridTorrent {
+		t.Fatalf("expected %q got %q\n", hybridTorrent, got)
+	}
+}
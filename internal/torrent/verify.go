@@ -0,0 +1,122 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Verify hashes the content of the torrent at path, the file of a
+// single-file torrent or the directory of a multi-file one, and returns
+// the indices of the pieces not matching their hash in Pieces.
+//
+// Missing files and the missing part of short files are read as zeros,
+// so that their pieces do not match. The piece holding the end of a file
+// longer than its length in the torrent is reported as not matching too,
+// even if the data past the end is ignored. Only the v1 hashes are verified:
+// Verify returns an error wrapping ErrUnsupportedVersion for a torrent
+// without them.
+func (i *Info) Verify(ctx context.Context, path string, h *Hasher) ([]int, error) {
+	if !i.IsV1() {
+		return nil, fmt.Errorf("%w: cannot verify a torrent without v1 pieces", ErrUnsupportedVersion)
+	}
+
+	files, err := i.localFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newContentReader(files, true)
+	defer r.Close()
+
+	hashes, err := h.Hash(ctx, r, i.PieceLength)
+	if err != nil {
+		return nil, err
+	}
+
+	long := map[int]bool{}
+	for _, index := range r.long {
+		long[i.lastPiece(files, index)] = true
+	}
+
+	var bad []int
+	for index := 0; index*sha1.Size < len(i.Pieces) || index*sha1.Size < len(hashes); index++ {
+		if long[index] || !bytes.Equal(pieceHash(i.Pieces, index), pieceHash(hashes, index)) {
+			bad = append(bad, index)
+		}
+	}
+
+	return bad, nil
+}
+
+// lastPiece returns the index of the piece holding the end of the file
+// at the given index of files, or the piece where it starts if it is
+// empty.
+func (i *Info) lastPiece(files []localFile, index int) int {
+	var end int64
+	for _, f := range files[:index+1] {
+		end += f.length
+	}
+	if files[index].length > 0 {
+		end--
+	}
+
+	last := int(end / i.PieceLength)
+	if n := len(i.Pieces) / sha1.Size; last >= n && n > 0 {
+		last = n - 1
+	}
+
+	return last
+}
+
+// pieceHash returns the hash of the piece index in pieces,
+// or nil if there is none.
+func pieceHash(pieces []byte, index int) []byte {
+	start, end := index*sha1.Size, (index+1)*sha1.Size
+	if end > len(pieces) {
+		return nil
+	}
+
+	return pieces[start:end]
+}
+
+// localFiles returns the local files of the content of the torrent at
+// path, checking that the path of each file stays inside path.
+func (i *Info) localFiles(path string) ([]localFile, error) {
+	if !i.IsMultiFile() {
+		return []localFile{{path, i.Length}}, nil
+	}
+
+	files := make([]localFile, 0, len(i.Files))
+	for index, f := range i.Files {
+		if f.IsPadding() {
+			files = append(files, localFile{length: f.Length})
+			continue
+		}
+
+		for _, elem := range f.Path {
+			if !isSafePathElem(elem) {
+				return nil, &ValidationError{
+					Key: fmt.Sprintf("info.files[%d].path", index),
+					Err: fmt.Errorf("%w: %q", ErrUnsafePath, elem),
+				}
+			}
+		}
+		files = append(files, localFile{
+			path:   filepath.Join(append([]string{path}, f.Path...)...),
+			length: f.Length,
+		})
+	}
+
+	return files, nil
+}
+
+// isSafePathElem reports whether elem can be used as a component of a
+// local path without escaping the directory it is joined to.
+func isSafePathElem(elem string) bool {
+	return elem != "" && elem != "." && elem != ".." &&
+		!strings.ContainsAny(elem, `/\`) && !filepath.IsAbs(elem) && filepath.VolumeName(elem) == ""
+}
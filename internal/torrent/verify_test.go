@@ -0,0 +1,125 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var verifyTestCases = []struct {
+	name   string
+	modify func(t *testing.T, dir string)
+	bad    []int
+}{
+	{
+		name:   "intact",
+		modify: func(t *testing.T, dir string) {},
+	},
+	{
+		name: "modified byte",
+		modify: func(t *testing.T, dir string) {
+			writeFiles(t, dir, map[string]string{"b": string(bytes.Repeat([]byte("b"), MinPieceLength-1)) + "x"})
+		},
+		bad: []int{2},
+	},
+	{
+		name: "missing file",
+		modify: func(t *testing.T, dir string) {
+			if err := os.Remove(filepath.Join(dir, "a")); err != nil {
+				t.Fatal(err)
+			}
+		},
+		bad: []int{0, 1},
+	},
+	{
+		name: "short file",
+		modify: func(t *testing.T, dir string) {
+			writeFiles(t, dir, map[string]string{"c": "c"})
+		},
+		bad: []int{2},
+	},
+	{
+		name: "long file",
+		modify: func(t *testing.T, dir string) {
+			writeFiles(t, dir, map[string]string{"c": "cccc"})
+		},
+		bad: []int{2},
+	},
+	{
+		name: "appended bytes",
+		modify: func(t *testing.T, dir string) {
+			f, err := os.OpenFile(filepath.Join(dir, "a"), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString("appended"); err != nil {
+				t.Fatal(err)
+			}
+		},
+		bad: []int{1},
+	},
+}
+
+func TestVerify(t *testing.T) {
+	for _, tc := range verifyTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// a spans pieces 0 and 1, b pieces 1 and 2, c the end of piece 2
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"a": string(bytes.Repeat([]byte("a"), MinPieceLength+1)),
+				"b": string(bytes.Repeat([]byte("b"), MinPieceLength)),
+				"c": "ccc",
+			})
+
+			torrent, err := (&Builder{PieceLength: MinPieceLength}).Build(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.modify(t, dir)
+
+			bad, err := torrent.Info.Verify(context.Background(), dir, &Hasher{Workers: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(bad, tc.bad) {
+				t.Fatalf("expected %v got %v\n", tc.bad, bad)
+			}
+		})
+	}
+}
+
+func TestVerifyUnsafePath(t *testing.T) {
+	info := Info{
+		Name:        "root",
+		PieceLength: MinPieceLength,
+		Pieces:      make([]byte, 20),
+		Files:       []File{{Length: 1, Path: []string{"..", "escape"}}},
+	}
+
+	_, err := info.Verify(context.Background(), t.TempDir(), &Hasher{})
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("expected %v got %v\n", ErrUnsafePath, err)
+	}
+}
+
+func TestVerifyInvalidPieceLength(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"file": "data"})
+
+	info := Info{
+		Name:        "file",
+		Length:      4,
+		PieceLength: -1,
+		Pieces:      make([]byte, 20),
+	}
+
+	_, err := info.Verify(context.Background(), filepath.Join(dir, "file"), &Hasher{})
+	if !errors.Is(err, ErrPieceLengthOutOfRange) {
+		t.Fatalf("expected %v got %v\n", ErrPieceLengthOutOfRange, err)
+	}
+}
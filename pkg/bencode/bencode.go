@@ -3,8 +3,8 @@ package bencode
 
 import (
 	"bytes"
-	"encoding"
 	"errors"
+	"fmt"
 	"io"
 	"sort"
 	"strconv"
@@ -37,8 +37,63 @@ var (
 	// ErrUnknownType is the error returned when a non-bencode package object
 	// (Integer, ByteString, List or Dict) is passed to the API
 	ErrUnknownType = errors.New("unknown object type")
+	// ErrNilValue is the error returned when a nil pointer or interface
+	// has to be encoded in a position where it cannot be omitted
+	ErrNilValue = errors.New("nil value")
+	// ErrCyclicValue is the error returned when a value to encode
+	// holds a pointer, map or slice that refers back to itself
+	ErrCyclicValue = errors.New("cyclic value")
+	// ErrInvalidTarget is the error returned when the value to decode
+	// into is not a non-nil pointer
+	ErrInvalidTarget = errors.New("decode target must be a non-nil pointer")
+	// ErrTypeMismatch is the error returned when a bencode value cannot
+	// be stored into the Go value passed to the API
+	ErrTypeMismatch = errors.New("type mismatch")
+	// ErrTrailingData is the error returned when unexpected data
+	// follows a complete bencode value
+	ErrTrailingData = errors.New("trailing data after bencode value")
+	// ErrInvalidLength is the error returned when a bytestring
+	// length prefix is not a valid non-negative integer
+	ErrInvalidLength = errors.New("invalid bytestring length")
+	// ErrNonCanonicalInteger is the error returned in canonical mode
+	// when an integer has a leading zero, a plus sign or is negative zero
+	ErrNonCanonicalInteger = errors.New("non-canonical integer")
+	// ErrNonCanonicalLength is the error returned in canonical mode
+	// when a bytestring length has a leading zero or a sign
+	ErrNonCanonicalLength = errors.New("non-canonical bytestring length")
+	// ErrUnsortedKeys is the error returned in canonical mode
+	// when dict keys are not sorted
+	ErrUnsortedKeys = errors.New("dict keys not sorted")
+	// ErrDuplicateKey is the error returned in canonical mode
+	// when the same key appears more than once in a dict
+	ErrDuplicateKey = errors.New("duplicate dict key")
+	// ErrNotAtValue is the error returned when Decode or Skip are called
+	// where the next element of a dict opened by Token is a key
+	ErrNotAtValue = errors.New("next element is a dict key, not a value")
+	// ErrStringTooLong is the error returned when a bytestring is
+	// longer than the MaxStringLength limit
+	ErrStringTooLong = errors.New("bytestring too long")
+	// ErrMaxDepth is the error returned when lists and dicts are
+	// nested deeper than the MaxDepth limit
+	ErrMaxDepth = errors.New("maximum nesting depth exceeded")
+	// ErrTooManyElements is the error returned when a value is made
+	// of more elements than the MaxElements limit
+	ErrTooManyElements = errors.New("too many elements")
+	// ErrInputTooLarge is the error returned when the encoding of
+	// a value is larger than the MaxInputSize limit
+	ErrInputTooLarge = errors.New("input too large")
+	// ErrInvalidQuery is the error returned when a path expression
+	// passed to Query is not valid
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrInvalidJSON is the error returned when a JSON value does not
+	// represent a bencode value
+	ErrInvalidJSON = errors.New("invalid JSON representation")
 )
 
+// maxIntegerLength is the length of the longest int64 literal,
+// "-9223372036854775808".
+const maxIntegerLength = 20
+
 // Integer represents the bencode integer type.
 type Integer struct {
 	value int64
@@ -54,28 +109,42 @@ func NewInteger(i int64) Integer {
 func (i Integer) MarshalBinary() ([]byte, error) {
 	var bb bytes.Buffer
 
-	bb.WriteByte(IntegerStart)
-	bb.WriteString(strconv.FormatInt(i.value, 10))
-	bb.WriteByte(IntegerEnd)
+	if _, err := i.WriteTo(&bb); err != nil {
+		return []byte{}, err
+	}
 
 	return bb.Bytes(), nil
 }
 
-func (i *Integer) unmarshal(bb *bytes.Buffer) error {
-	start, err := bb.ReadByte()
-	if err != nil {
-		return err
-	}
-	if start != IntegerStart {
-		return ErrWrongStartByte
-	}
-	buf, err := bb.ReadBytes(IntegerEnd)
+// WriteTo satisfies the io.WriterTo interface to write an Integer
+// in binary form directly to w.
+func (i Integer) WriteTo(w io.Writer) (int64, error) {
+	return encodeTo(w, i)
+}
+
+func (i Integer) encode(e *encodeState) error {
+	e.writeInt(i.value)
+
+	return nil
+}
+
+func (i *Integer) unmarshal(ds *decodeState) error {
+	off := ds.off
+	// read the literals longer than maxIntegerLength too, so that a
+	// valid integer out of the int64 range is told from a syntax error
+	literal, err := ds.integerLiteral(ds.maxBigIntegerLength())
 	if err != nil {
 		return err
 	}
 
-	value, err := strconv.ParseInt(string(buf[:len(buf)-1]), 10, 64)
+	// an Integer is decoded as a Value, unless the caller of
+	// Unmarshal or Decode asked for something else: see decodeState.value
+	value, err := strconv.ParseInt(string(literal), 10, 64)
 	if err != nil {
+		err = ds.integerError(off, string(literal), valueType, err)
+		if te, ok := err.(*TypeError); ok {
+			te.Value += " out of the int64 range (decode it into a BigInteger or a big.Int)"
+		}
 		return err
 	}
 	i.value = value
@@ -86,7 +155,7 @@ func (i *Integer) unmarshal(bb *bytes.Buffer) error {
 // UnmarshalBinary satisfies the encoding.BinaryUnmarshaler interface
 // to unmarshal an Integer from binary data.
 func (i *Integer) UnmarshalBinary(data []byte) error {
-	return i.unmarshal(bytes.NewBuffer(data))
+	return i.unmarshal(newDecodeState(bytes.NewBuffer(data)))
 }
 
 // Value returns a representation of the Integer using Go
@@ -110,27 +179,35 @@ func NewByteString(s string) ByteString {
 func (bs ByteString) MarshalBinary() ([]byte, error) {
 	var bb bytes.Buffer
 
-	bb.WriteString(strconv.Itoa(len(bs.value)))
-	bb.WriteByte(':')
-	bb.WriteString(bs.value)
+	if _, err := bs.WriteTo(&bb); err != nil {
+		return []byte{}, err
+	}
 
 	return bb.Bytes(), nil
 }
 
-func (bs *ByteString) unmarshal(bb *bytes.Buffer) error {
-	szBuf, err := bb.ReadBytes(ByteStringDelimiter)
+// WriteTo satisfies the io.WriterTo interface to write a ByteString
+// in binary form directly to w.
+func (bs ByteString) WriteTo(w io.Writer) (int64, error) {
+	return encodeTo(w, bs)
+}
+
+func (bs ByteString) encode(e *encodeState) error {
+	e.writeString(bs.value)
+
+	return nil
+}
+
+func (bs *ByteString) unmarshal(ds *decodeState) error {
+	sz, err := ds.readLength()
 	if err != nil {
 		return err
 	}
-	sz, err := strconv.Atoi(string(szBuf[:len(szBuf)-1]))
+	value, err := ds.readString(sz)
 	if err != nil {
-		return err
-	}
-	dataBuf := make([]byte, sz)
-	if _, err := io.ReadFull(bb, dataBuf); err != nil {
-		return err
+		return ds.syntaxError(ds.off, "bytestring", err)
 	}
-	bs.value = string(dataBuf)
+	bs.value = value
 
 	return nil
 }
@@ -138,7 +215,7 @@ func (bs *ByteString) unmarshal(bb *bytes.Buffer) error {
 // UnmarshalBinary satisfies the encoding.BinaryUnmarshaler interface
 // to unmarshal a ByteString from binary data.
 func (bs *ByteString) UnmarshalBinary(data []byte) error {
-	return bs.unmarshal(bytes.NewBuffer(data))
+	return bs.unmarshal(newDecodeState(bytes.NewBuffer(data)))
 }
 
 // Value returns a representation of the ByteString using Go
@@ -148,12 +225,20 @@ func (bs ByteString) Value() string {
 }
 
 // List represents the bencode list type.
+//
+// A List has copy semantics with respect to Append and Insert: they
+// never modify the elements seen by other copies of the List, like
+// one returned by Value.AsList. A Dict, instead, shares its map with
+// its copies, so Set and Delete are visible through all of them.
 type List struct {
-	value []interface{}
+	value []Value
 }
 
 // NewList returns a bencode List initialized with the given parameter.
-func NewList(l []interface{}) List {
+// NewList panics if any of the values is nil or a pointer.
+func NewList(l []Value) List {
+	checkValues("NewList", l)
+
 	return List{l}
 }
 
@@ -162,117 +247,146 @@ func NewList(l []interface{}) List {
 func (l List) MarshalBinary() ([]byte, error) {
 	var bb bytes.Buffer
 
-	bb.WriteByte(ListStart)
+	if _, err := l.WriteTo(&bb); err != nil {
+		return []byte{}, err
+	}
+
+	return bb.Bytes(), nil
+}
+
+// WriteTo satisfies the io.WriterTo interface to write a List
+// in binary form directly to w.
+func (l List) WriteTo(w io.Writer) (int64, error) {
+	return encodeTo(w, l)
+}
+
+func (l List) encode(e *encodeState) error {
+	e.putByte(ListStart)
 	for _, v := range l.value {
-		value, ok := v.(encoding.BinaryMarshaler)
-		if !ok {
-			return []byte{}, ErrUnknownType
+		if err := e.encodeElement(v); err != nil {
+			return err
 		}
-		buf, err := value.MarshalBinary()
-		if err != nil {
-			return []byte{}, err
+		if e.err != nil {
+			return e.err
 		}
-		bb.Write(buf)
 	}
-	bb.WriteByte(ListEnd)
+	e.putByte(ListEnd)
 
-	return bb.Bytes(), nil
+	return nil
 }
 
-func (l *List) unmarshal(bb *bytes.Buffer) error {
-	l.value = []interface{}{}
+func (l *List) unmarshal(ds *decodeState) error {
+	l.value = []Value{}
 
-	start, err := bb.ReadByte()
+	off := ds.off
+	start, err := ds.readByte()
 	if err != nil {
-		return err
+		return ds.syntaxError(ds.off, "list", err)
 	}
 	if start != ListStart {
-		return ErrWrongStartByte
+		return ds.syntaxError(off, "list", ErrWrongStartByte)
+	}
+	if err := ds.enter(off); err != nil {
+		return err
 	}
 
-	for {
-		cur, err := bb.ReadByte()
+	for i := 0; ; i++ {
+		cur, err := ds.peek()
 		if err != nil {
-			return err
+			return ds.syntaxError(ds.off, "list element or end", err)
+		}
+		if cur == ListEnd {
+			break
 		}
 
-		switch cur {
-		case ListEnd:
-			return nil
-		case IntegerStart:
-			if err := bb.UnreadByte(); err != nil {
-				return err
-			}
-			obj := Integer{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			l.value = append(l.value, obj)
-		case ListStart:
-			if err := bb.UnreadByte(); err != nil {
-				return err
-			}
-			obj := List{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			l.value = append(l.value, obj)
-		case DictStart:
-			if err := bb.UnreadByte(); err != nil {
-				return err
-			}
-			obj := Dict{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			l.value = append(l.value, obj)
-		default:
-			if err := bb.UnreadByte(); err != nil {
-				return err
-			}
-			obj := ByteString{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			l.value = append(l.value, obj)
+		ds.pushIndex(i)
+		obj, err := ds.object()
+		if err != nil {
+			return err
 		}
+		ds.popPath()
+
+		l.value = append(l.value, obj)
 	}
+	ds.leave()
+
+	_, err = ds.readByte()
+	return err
 }
 
 // UnmarshalBinary satisfies the encoding.BinaryUnmarshaler interface
 // to unmarshal a List from binary data.
 func (l *List) UnmarshalBinary(data []byte) error {
-	return l.unmarshal(bytes.NewBuffer(data))
+	return l.unmarshal(newDecodeState(bytes.NewBuffer(data)))
 }
 
 // Value returns a representation of the List using Go
 // standard data type []interface{}.
 func (l List) Value() []interface{} {
 	values := make([]interface{}, 0, len(l.value))
-
 	for _, v := range l.value {
-		switch value := v.(type) {
-		case Integer:
-			values = append(values, value.Value())
-		case ByteString:
-			values = append(values, value.Value())
-		case List:
-			values = append(values, value.Value())
-		case Dict:
-			values = append(values, value.Value())
-		}
+		values = append(values, nativeValue(v))
 	}
 
 	return values
 }
 
+// Len returns the number of elements in the List.
+func (l List) Len() int {
+	return len(l.value)
+}
+
+// Index returns the element at index i of the List, and false
+// if i is out of range.
+func (l List) Index(i int) (Value, bool) {
+	if i < 0 || i >= len(l.value) {
+		return nil, false
+	}
+
+	return l.value[i], true
+}
+
+// Append adds the values at the end of the List. Append panics if any
+// of the values is nil or a pointer.
+func (l *List) Append(v ...Value) {
+	checkValues("List.Append", v)
+
+	// limit the capacity, so that append allocates a new backing
+	// array instead of writing into one shared with other copies
+	n := len(l.value)
+	l.value = append(l.value[:n:n], v...)
+}
+
+// Insert inserts the values at index i of the List, shifting the
+// following elements. Insert panics if i is out of range or if any
+// of the values is nil or a pointer.
+func (l *List) Insert(i int, v ...Value) {
+	if i < 0 || i > len(l.value) {
+		panic("bencode: List.Insert index out of range")
+	}
+	checkValues("List.Insert", v)
+
+	value := make([]Value, 0, len(l.value)+len(v))
+	value = append(value, l.value[:i]...)
+	value = append(value, v...)
+	l.value = append(value, l.value[i:]...)
+}
+
 // Dict represents the bencode dict type.
+//
+// Copies of a Dict share the same map, so Set and Delete on any of
+// them are visible through all of them.
 type Dict struct {
-	value map[ByteString]interface{}
+	value map[ByteString]Value
 }
 
 // NewDict returns a bencode Dict initialized with the given parameter.
-func NewDict(d map[ByteString]interface{}) Dict {
+// NewDict panics if any of the values is nil or a pointer.
+func NewDict(d map[ByteString]Value) Dict {
+	for _, v := range d {
+		checkValue("NewDict", v)
+	}
+
 	return Dict{d}
 }
 
@@ -281,99 +395,69 @@ func NewDict(d map[ByteString]interface{}) Dict {
 func (d Dict) MarshalBinary() ([]byte, error) {
 	var bb bytes.Buffer
 
-	keys := make([]ByteString, 0, len(d.value))
-	for k := range d.value {
-		keys = append(keys, k)
+	if _, err := d.WriteTo(&bb); err != nil {
+		return []byte{}, err
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i].value < keys[j].value
-	})
 
-	bb.WriteByte(DictStart)
-	for _, k := range keys {
-		buf, err := k.MarshalBinary()
-		if err != nil {
-			return []byte{}, err
-		}
-		bb.Write(buf)
+	return bb.Bytes(), nil
+}
+
+// WriteTo satisfies the io.WriterTo interface to write a Dict
+// in binary form directly to w.
+func (d Dict) WriteTo(w io.Writer) (int64, error) {
+	return encodeTo(w, d)
+}
 
-		value, ok := d.value[k].(encoding.BinaryMarshaler)
-		if !ok {
-			return []byte{}, ErrUnknownType
+func (d Dict) encode(e *encodeState) error {
+	e.putByte(DictStart)
+	for _, k := range d.Keys() {
+		e.writeString(k)
+		if err := e.encodeElement(d.value[ByteString{k}]); err != nil {
+			return err
 		}
-		buf, err = value.MarshalBinary()
-		if err != nil {
-			return []byte{}, err
+		if e.err != nil {
+			return e.err
 		}
-		bb.Write(buf)
 	}
-	bb.WriteByte(DictEnd)
+	e.putByte(DictEnd)
 
-	return bb.Bytes(), nil
+	return nil
 }
 
-func (d *Dict) unmarshal(bb *bytes.Buffer) error {
-	d.value = map[ByteString]interface{}{}
+func (d *Dict) unmarshal(ds *decodeState) error {
+	d.value = map[ByteString]Value{}
 
-	start, err := bb.ReadByte()
+	off := ds.off
+	start, err := ds.readByte()
 	if err != nil {
-		return err
+		return ds.syntaxError(ds.off, "dict", err)
 	}
 	if start != DictStart {
-		return ErrWrongStartByte
+		return ds.syntaxError(off, "dict", ErrWrongStartByte)
+	}
+	if err := ds.enter(off); err != nil {
+		return err
 	}
 
+	var keys keyOrder
 	for {
-		cur, err := bb.ReadByte()
+		key, err := ds.dictKey(&keys)
 		if err != nil {
 			return err
 		}
-		if cur == DictEnd {
+		if key == nil {
+			ds.leave()
 			break
 		}
-		if err := bb.UnreadByte(); err != nil {
-			return err
-		}
-
-		key := ByteString{}
-		if err := key.unmarshal(bb); err != nil {
-			return err
-		}
 
-		cur, err = bb.ReadByte()
+		ds.pushKey(key.value)
+		obj, err := ds.object()
 		if err != nil {
 			return err
 		}
-		if err := bb.UnreadByte(); err != nil {
-			return err
-		}
+		ds.popPath()
 
-		switch cur {
-		case IntegerStart:
-			obj := Integer{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			d.value[key] = obj
-		case ListStart:
-			obj := List{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			d.value[key] = obj
-		case DictStart:
-			obj := Dict{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			d.value[key] = obj
-		default:
-			obj := ByteString{}
-			if err := obj.unmarshal(bb); err != nil {
-				return err
-			}
-			d.value[key] = obj
-		}
+		d.value[*key] = obj
 	}
 
 	return nil
@@ -382,7 +466,7 @@ func (d *Dict) unmarshal(bb *bytes.Buffer) error {
 // UnmarshalBinary satisfies the encoding.BinaryUnmarshaler interface
 // to unmarshal a Dict from binary data.
 func (d *Dict) UnmarshalBinary(data []byte) error {
-	return d.unmarshal(bytes.NewBuffer(data))
+	return d.unmarshal(newDecodeState(bytes.NewBuffer(data)))
 }
 
 // Value returns a representation of the Dict using Go
@@ -390,87 +474,75 @@ func (d *Dict) UnmarshalBinary(data []byte) error {
 func (d Dict) Value() map[string]interface{} {
 	values := make(map[string]interface{}, len(d.value))
 	for k, v := range d.value {
-		switch value := v.(type) {
-		case Integer:
-			values[k.Value()] = value.Value()
-		case ByteString:
-			values[k.Value()] = value.Value()
-		case List:
-			values[k.Value()] = value.Value()
-		case Dict:
-			values[k.Value()] = value.Value()
-		}
+		values[k.Value()] = nativeValue(v)
 	}
-	return values
-}
 
-// Encoder writes bencode values to an output stream.
-type Encoder struct {
-	w io.Writer
+	return values
 }
 
-// NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+// Len returns the number of keys in the Dict.
+func (d Dict) Len() int {
+	return len(d.value)
 }
 
-// Encode writes the bencode encoding of v to the stream.
-func (e *Encoder) Encode(v interface{}) error {
-	switch value := v.(type) {
-	case encoding.BinaryMarshaler:
-		buf, err := value.MarshalBinary()
-		if err != nil {
-			return err
-		}
-		if _, err := e.w.Write(buf); err != nil {
-			return err
-		}
-	default:
-		return ErrUnknownType
+// Keys returns the keys of the Dict, sorted as raw bytes
+// like in their bencode encoding.
+func (d Dict) Keys() []string {
+	keys := make([]string, 0, len(d.value))
+	for k := range d.value {
+		keys = append(keys, k.value)
 	}
+	sort.Strings(keys)
 
-	return nil
+	return keys
 }
 
-// A Decoder reads and decodes bencode values from an input stream.
-type Decoder struct {
-	r io.Reader
+// Get returns the value of the given key, and false if the
+// key is not in the Dict.
+func (d Dict) Get(key string) (Value, bool) {
+	v, ok := d.value[ByteString{key}]
+	return v, ok
 }
 
-// NewDecoder returns a new decoder that reads from r.
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r}
+// Has reports whether the key is in the Dict.
+func (d Dict) Has(key string) bool {
+	_, ok := d.value[ByteString{key}]
+	return ok
 }
 
-// Decode reads the next bencode-encoded value from its input and
-// stores it in the value pointed to by v.
-func (d *Decoder) Decode(v interface{}) error {
-	var bb bytes.Buffer
-
-	if _, err := bb.ReadFrom(d.r); err != nil {
-		return err
+// Set sets the value of the given key, adding the key to the
+// Dict if it is not already there. Set panics if v is nil or a
+// pointer.
+func (d *Dict) Set(key string, v Value) {
+	checkValue("Dict.Set", v)
+	if d.value == nil {
+		d.value = map[ByteString]Value{}
 	}
 
-	switch value := v.(type) {
-	case *Integer:
-		if err := value.unmarshal(&bb); err != nil {
-			return err
-		}
-	case *ByteString:
-		if err := value.unmarshal(&bb); err != nil {
-			return err
-		}
-	case *List:
-		if err := value.unmarshal(&bb); err != nil {
-			return err
-		}
-	case *Dict:
-		if err := value.unmarshal(&bb); err != nil {
-			return err
-		}
+	d.value[ByteString{key}] = v
+}
+
+// Delete removes the key from the Dict, if present.
+func (d *Dict) Delete(key string) {
+	delete(d.value, ByteString{key})
+}
+
+// checkValue panics if v is not one of the concrete value types, so
+// that a List or a Dict never holds a value that cannot be encoded.
+// The methods of Value have value receivers, so pointers to the value
+// types implement it too and must be rejected here.
+func checkValue(fn string, v Value) {
+	switch v.(type) {
+	case Integer, BigInteger, ByteString, List, Dict:
+	case nil:
+		panic("bencode: " + fn + " with nil value")
 	default:
-		return ErrUnknownType
+		panic(fmt.Sprintf("bencode: %s with invalid value of type %T", fn, v))
 	}
+}
 
-	return nil
+func checkValues(fn string, values []Value) {
+	for _, v := range values {
+		checkValue(fn, v)
+	}
 }
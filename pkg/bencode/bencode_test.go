@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -313,15 +316,15 @@ var listMarshalTestCases = []struct {
 	},
 	{
 		name:     "integer and bytestring",
-		input:    List{[]interface{}{Integer{12}, ByteString{"test"}}},
+		input:    List{[]Value{Integer{12}, ByteString{"test"}}},
 		expected: []byte{'l', 'i', '1', '2', 'e', '4', ':', 't', 'e', 's', 't', 'e'},
 	},
 	{
 		name: "integer and inner list",
 		input: List{
-			[]interface{}{
+			[]Value{
 				List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 				Integer{5},
 			},
@@ -336,12 +339,12 @@ var listMarshalTestCases = []struct {
 	{
 		name: "inner list and inner dict",
 		input: List{
-			[]interface{}{
+			[]Value{
 				List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 				Dict{
-					map[ByteString]interface{}{
+					map[ByteString]Value{
 						{"integer"}: Integer{12},
 						{"string"}:  ByteString{"test"},
 					},
@@ -388,7 +391,7 @@ var listUnmarshalTestCases = []struct {
 	{
 		name:     "integer and bytestring",
 		input:    []byte{'l', 'i', '1', '2', 'e', '4', ':', 't', 'e', 's', 't', 'e'},
-		expected: List{[]interface{}{Integer{12}, ByteString{"test"}}},
+		expected: List{[]Value{Integer{12}, ByteString{"test"}}},
 	},
 	{
 		name: "integer and inner list",
@@ -399,9 +402,9 @@ var listUnmarshalTestCases = []struct {
 			'e',
 		},
 		expected: List{
-			[]interface{}{
+			[]Value{
 				List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 				Integer{5},
 			},
@@ -419,12 +422,12 @@ var listUnmarshalTestCases = []struct {
 			'e',
 		},
 		expected: List{
-			[]interface{}{
+			[]Value{
 				List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 				Dict{
-					map[ByteString]interface{}{
+					map[ByteString]Value{
 						{"integer"}: Integer{12},
 						{"string"}:  ByteString{"test"},
 					},
@@ -500,7 +503,7 @@ var dictMarshalTestCases = []struct {
 	},
 	{
 		name: "integer and bytestring",
-		input: Dict{map[ByteString]interface{}{
+		input: Dict{map[ByteString]Value{
 			{"one"}: Integer{12},
 			{"two"}: ByteString{"test"},
 		},
@@ -515,10 +518,10 @@ var dictMarshalTestCases = []struct {
 	{
 		name: "integer and inner list",
 		input: Dict{
-			map[ByteString]interface{}{
+			map[ByteString]Value{
 				{"integer"}: Integer{12},
 				{"list"}: List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 			},
 		},
@@ -566,7 +569,7 @@ var dictUnmarshalTestCases = []struct {
 			'e',
 		},
 		expected: Dict{
-			map[ByteString]interface{}{
+			map[ByteString]Value{
 				{"one"}: Integer{12},
 				{"two"}: ByteString{"test"},
 			},
@@ -582,10 +585,10 @@ var dictUnmarshalTestCases = []struct {
 			'e',
 		},
 		expected: Dict{
-			map[ByteString]interface{}{
+			map[ByteString]Value{
 				{"integer"}: Integer{12},
 				{"list"}: List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 			},
 		},
@@ -664,7 +667,7 @@ var encoderTestCases = []struct {
 	{
 		name: "dict with integer and bytestring",
 		input: Dict{
-			map[ByteString]interface{}{
+			map[ByteString]Value{
 				{"one"}: Integer{12},
 				{"two"}: ByteString{"test"},
 			},
@@ -679,10 +682,10 @@ var encoderTestCases = []struct {
 	{
 		name: "dict with integer and inner list",
 		input: Dict{
-			map[ByteString]interface{}{
+			map[ByteString]Value{
 				{"integer"}: Integer{12},
 				{"list"}: List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 			},
 		},
@@ -697,9 +700,9 @@ var encoderTestCases = []struct {
 	{
 		name: "list with integer and inner list",
 		input: List{
-			[]interface{}{
+			[]Value{
 				List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 				Integer{5},
 			},
@@ -748,7 +751,7 @@ var decoderDictTestCases = []struct {
 			'e',
 		},
 		expected: Dict{
-			map[ByteString]interface{}{
+			map[ByteString]Value{
 				{"one"}: Integer{12},
 				{"two"}: ByteString{"test"},
 			},
@@ -764,10 +767,10 @@ var decoderDictTestCases = []struct {
 			'e',
 		},
 		expected: Dict{
-			map[ByteString]interface{}{
+			map[ByteString]Value{
 				{"integer"}: Integer{12},
 				{"list"}: List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 			},
 		},
@@ -809,9 +812,9 @@ var decoderListTestCases = []struct {
 			'e',
 		},
 		expected: List{
-			[]interface{}{
+			[]Value{
 				List{
-					[]interface{}{ByteString{"test"}, ByteString{"again"}},
+					[]Value{ByteString{"test"}, ByteString{"again"}},
 				},
 				Integer{5},
 			},
@@ -839,17 +842,17 @@ func TestDecoderList(t *testing.T) {
 
 func BenchmarkBencodeMarshal(b *testing.B) {
 	data := Dict{
-		map[ByteString]interface{}{
+		map[ByteString]Value{
 			{"announce"}: ByteString{"udp://tracker.publicbt.com:80/announce"},
 			{"announce-list"}: List{
-				[]interface{}{
+				[]Value{
 					ByteString{"udp://tracker.publicbt.com:80/announce"},
 					ByteString{"udp://tracker.openbittorrent.com:80/announce"},
 				},
 			},
 			{"comment"}: ByteString{"Debian CD from cdimage.debian.org"},
 			{"info"}: Dict{
-				map[ByteString]interface{}{
+				map[ByteString]Value{
 					{"name"}:         ByteString{"debian-8.8.0-arm64-netinst.iso"},
 					{"length"}:       Integer{170917888},
 					{"piece length"}: Integer{262144},
@@ -869,6 +872,32 @@ func BenchmarkBencodeMarshal(b *testing.B) {
 	}
 }
 
+// nestedDict returns a Dict nested depth times, with each level
+// holding a few bytestrings and integers besides the nested Dict.
+func nestedDict(depth int) Dict {
+	d := Dict{map[ByteString]Value{
+		{"name"}:   ByteString{"debian-8.8.0-arm64-netinst.iso"},
+		{"length"}: Integer{170917888},
+		{"pieces"}: ByteString{strings.Repeat("x", 1024)},
+	}}
+	if depth > 0 {
+		d.value[ByteString{"files"}] = List{[]Value{nestedDict(depth - 1)}}
+	}
+
+	return d
+}
+
+func BenchmarkBencodeMarshalNested(b *testing.B) {
+	data := nestedDict(16)
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if err := NewEncoder(io.Discard).Encode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkBencodeUnmarshal(b *testing.B) {
 	data := []byte("d4:infod6:lengthi170917888e12:piece lengthi262144e4:name30:debian-8.8.0-arm64-netinst.isoe8:announce38:udp://tracker.publicbt.com:80/announce13:announce-listll38:udp://tracker.publicbt.com:80/announceel44:udp://tracker.openbittorrent.com:80/announceee7:comment33:Debian CD from cdimage.debian.orge")
 
@@ -881,3 +910,221 @@ func BenchmarkBencodeUnmarshal(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkBencodeDecodeBytes(b *testing.B) {
+	data := []byte("d4:infod6:lengthi170917888e12:piece lengthi262144e4:name30:debian-8.8.0-arm64-netinst.isoe8:announce38:udp://tracker.publicbt.com:80/announce13:announce-listll38:udp://tracker.publicbt.com:80/announceel44:udp://tracker.openbittorrent.com:80/announceee7:comment33:Debian CD from cdimage.debian.orge")
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, err := DecodeBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// piecesTorrent returns the encoding of a torrent with a 1 MiB
+// "pieces" bytestring, like the one of a 5 GiB file.
+func piecesTorrent() []byte {
+	pieces := strings.Repeat("x", 1<<20)
+	return []byte("d4:infod6:lengthi5368709120e4:name4:file12:piece lengthi262144e6:pieces" +
+		strconv.Itoa(len(pieces)) + ":" + pieces + "ee")
+}
+
+func BenchmarkBencodeUnmarshalPieces(b *testing.B) {
+	data := piecesTorrent()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		d := Dict{}
+		if err := d.UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBencodeDecodeBytesPieces(b *testing.B) {
+	data := piecesTorrent()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, err := DecodeBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDictMutation(t *testing.T) {
+	var d Dict
+	d.Set("b", Integer{2})
+	d.Set("a", ByteString{"one"})
+	d.Set("c", List{})
+	d.Set("b", Integer{3})
+	d.Delete("c")
+	d.Delete("missing")
+
+	if d.Len() != 2 {
+		t.Fatalf("expected length %d got %d\n", 2, d.Len())
+	}
+	if keys := d.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatalf("expected %v got %v\n", []string{"a", "b"}, keys)
+	}
+	if d.Has("c") {
+		t.Fatal("expected deleted key to be missing")
+	}
+	if v, ok := d.Get("b"); !ok || v != (Integer{3}) {
+		t.Fatalf("expected %v got %v\n", Integer{3}, v)
+	}
+
+	buf, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "d1:a3:one1:bi3ee" {
+		t.Fatalf("expected %q got %q\n", "d1:a3:one1:bi3ee", buf)
+	}
+}
+
+var listInsertTestCases = []struct {
+	name     string
+	index    int
+	values   []Value
+	expected []Value
+}{
+	{
+		name:     "at start",
+		index:    0,
+		values:   []Value{Integer{0}},
+		expected: []Value{Integer{0}, Integer{1}, Integer{2}},
+	},
+	{
+		name:     "in the middle",
+		index:    1,
+		values:   []Value{ByteString{"a"}, ByteString{"b"}},
+		expected: []Value{Integer{1}, ByteString{"a"}, ByteString{"b"}, Integer{2}},
+	},
+	{
+		name:     "at end",
+		index:    2,
+		values:   []Value{Integer{3}},
+		expected: []Value{Integer{1}, Integer{2}, Integer{3}},
+	},
+}
+
+func TestListMutation(t *testing.T) {
+	for _, tc := range listInsertTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var l List
+			l.Append(Integer{1}, Integer{2})
+			l.Insert(tc.index, tc.values...)
+
+			if l.Len() != len(tc.expected) {
+				t.Fatalf("expected length %d got %d\n", len(tc.expected), l.Len())
+			}
+			for i, want := range tc.expected {
+				if got, ok := l.Index(i); !ok || got != want {
+					t.Fatalf("expected %v got %v\n", want, got)
+				}
+			}
+			if _, ok := l.Index(l.Len()); ok {
+				t.Fatal("expected index out of range")
+			}
+		})
+	}
+}
+
+var nilValueTestCases = []struct {
+	name   string
+	mutate func()
+}{
+	{
+		name:   "NewList",
+		mutate: func() { NewList([]Value{Integer{1}, nil}) },
+	},
+	{
+		name:   "NewDict",
+		mutate: func() { NewDict(map[ByteString]Value{{"a"}: nil}) },
+	},
+	{
+		name:   "List.Append",
+		mutate: func() { var l List; l.Append(nil) },
+	},
+	{
+		name:   "List.Insert",
+		mutate: func() { var l List; l.Insert(0, Integer{1}, nil) },
+	},
+	{
+		name:   "Dict.Set",
+		mutate: func() { var d Dict; d.Set("a", nil) },
+	},
+	{
+		name:   "NewList nil pointer",
+		mutate: func() { NewList([]Value{(*Integer)(nil)}) },
+	},
+	{
+		name:   "NewDict pointer",
+		mutate: func() { NewDict(map[ByteString]Value{{"a"}: &ByteString{"b"}}) },
+	},
+	{
+		name:   "List.Append pointer",
+		mutate: func() { var l List; l.Append(&Integer{1}) },
+	},
+	{
+		name:   "List.Insert pointer",
+		mutate: func() { var l List; l.Insert(0, &List{}) },
+	},
+	{
+		name:   "Dict.Set pointer",
+		mutate: func() { var d Dict; d.Set("a", &Dict{}) },
+	},
+	{
+		name:   "Dict.Set BigInteger pointer",
+		mutate: func() { var d Dict; d.Set("a", &BigInteger{}) },
+	},
+}
+
+func TestNilValue(t *testing.T) {
+	for _, tc := range nilValueTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic on nil value")
+				}
+			}()
+
+			tc.mutate()
+		})
+	}
+}
+
+func TestListCopySemantics(t *testing.T) {
+	var d Dict
+	if err := d.UnmarshalBinary([]byte("d1:ali1ei2ei3eee")); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := d.Get("a")
+	inserted, _ := v.AsList()
+	inserted.Insert(0, Integer{0})
+	appended, _ := v.AsList()
+	appended.Append(Integer{4})
+
+	buf, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "d1:ali1ei2ei3eee" {
+		t.Fatalf("expected %q got %q\n", "d1:ali1ei2ei3eee", buf)
+	}
+
+	// appending to a List must not overwrite the elements appended
+	// to another copy sharing its spare capacity
+	l := NewList(make([]Value, 0, 4))
+	a, b := l, l
+	a.Append(Integer{1})
+	b.Append(Integer{2})
+	if got, _ := a.Index(0); got != (Integer{1}) {
+		t.Fatalf("expected %v got %v\n", Integer{1}, got)
+	}
+}
@@ -0,0 +1,124 @@
+package bencode
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// BigInteger represents a bencode integer of arbitrary size.
+//
+// The bencode format does not limit the size of integers: BigInteger can
+// hold the values that do not fit the int64 used by Integer.
+type BigInteger struct {
+	value *big.Int
+}
+
+var (
+	bigIntegerType = reflect.TypeOf(BigInteger{})
+	bigIntType     = reflect.TypeOf(big.Int{})
+)
+
+// NewBigInteger returns a bencode BigInteger initialized with
+// a copy of the given parameter.
+func NewBigInteger(i *big.Int) BigInteger {
+	return BigInteger{new(big.Int).Set(i)}
+}
+
+// MarshalBinary satisfies the encoding.BinaryMarshaler interface
+// to marshal a BigInteger in binary form.
+func (i BigInteger) MarshalBinary() ([]byte, error) {
+	var bb bytes.Buffer
+
+	if _, err := i.WriteTo(&bb); err != nil {
+		return []byte{}, err
+	}
+
+	return bb.Bytes(), nil
+}
+
+// WriteTo satisfies the io.WriterTo interface to write a BigInteger
+// in binary form directly to w.
+func (i BigInteger) WriteTo(w io.Writer) (int64, error) {
+	return encodeTo(w, i)
+}
+
+func (i BigInteger) encode(e *encodeState) error {
+	e.putByte(IntegerStart)
+	if i.value == nil {
+		e.putByte('0')
+	} else {
+		e.putString(i.value.String())
+	}
+	e.putByte(IntegerEnd)
+
+	return nil
+}
+
+func (i *BigInteger) unmarshal(ds *decodeState) error {
+	off := ds.off
+	literal, err := ds.integerLiteral(ds.maxBigIntegerLength())
+	if err != nil {
+		return err
+	}
+
+	value, ok := new(big.Int).SetString(string(literal), 10)
+	if !ok {
+		err := &strconv.NumError{
+			Func: "SetString",
+			Num:  string(literal),
+			Err:  strconv.ErrSyntax,
+		}
+		return ds.syntaxError(off, "integer", err)
+	}
+	i.value = value
+
+	return nil
+}
+
+// UnmarshalBinary satisfies the encoding.BinaryUnmarshaler interface
+// to unmarshal a BigInteger from binary data.
+func (i *BigInteger) UnmarshalBinary(data []byte) error {
+	return i.unmarshal(newDecodeState(bytes.NewBuffer(data)))
+}
+
+// Value returns a representation of the BigInteger using Go
+// standard data type *big.Int.
+func (i BigInteger) Value() *big.Int {
+	if i.value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(i.value)
+}
+
+// maxBigIntegerLength returns the maximum length of the literal of
+// a BigInteger, or -1 if it is not limited.
+func (ds *decodeState) maxBigIntegerLength() int {
+	if ds.limits.MaxBigIntegerLength > 0 {
+		return ds.limits.MaxBigIntegerLength
+	}
+	return -1
+}
+
+// integerObject decodes the next bencode integer into an Integer or,
+// if big integers are enabled and the value does not fit an int64,
+// into a BigInteger.
+func (ds *decodeState) integerObject() (Value, error) {
+	if !ds.useBigInt {
+		obj := Integer{}
+		err := obj.unmarshal(ds)
+		return obj, err
+	}
+
+	obj := BigInteger{}
+	if err := obj.unmarshal(ds); err != nil {
+		return nil, err
+	}
+	if obj.value.IsInt64() {
+		return Integer{obj.value.Int64()}, nil
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,185 @@
+package bencode
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func bigIntFromString(s string) *big.Int {
+	i, _ := new(big.Int).SetString(s, 10)
+	return i
+}
+
+var bigIntegerTestCases = []struct {
+	name     string
+	input    string
+	expected *big.Int
+}{
+	{"zero", "i0e", big.NewInt(0)},
+	{"small", "i-42e", big.NewInt(-42)},
+	{"larger than int64", "i18446744073709551616e", bigIntFromString("18446744073709551616")},
+	{"smaller than int64", "i-99999999999999999999999999e", bigIntFromString("-99999999999999999999999999")},
+}
+
+func TestBigIntegerMarshalUnmarshal(t *testing.T) {
+	for _, tc := range bigIntegerTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var i BigInteger
+			if err := i.UnmarshalBinary([]byte(tc.input)); err != nil {
+				t.Fatal(err)
+			}
+			if i.Value().Cmp(tc.expected) != 0 {
+				t.Fatalf("expected %v got %v\n", tc.expected, i.Value())
+			}
+
+			buf, err := NewBigInteger(tc.expected).MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(buf) != tc.input {
+				t.Fatalf("expected %q got %q\n", tc.input, buf)
+			}
+		})
+	}
+}
+
+func TestDecoderUseBigInt(t *testing.T) {
+	input := "d3:bigi18446744073709551616e5:smalli7ee"
+
+	var v interface{}
+	err := NewDecoder(strings.NewReader(input)).Decode(&v)
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected error %v, got %v", ErrTypeMismatch, err)
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	dec.UseBigInt()
+	var d Dict
+	if err := dec.Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"big":   bigIntFromString("18446744073709551616"),
+		"small": int64(7),
+	}
+	if got := d.Value(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+}
+
+var integerRangeTestCases = []struct {
+	name     string
+	input    string
+	target   interface{}
+	expected reflect.Type
+}{
+	{
+		name:     "interface",
+		input:    "i99999999999999999999999e",
+		target:   new(interface{}),
+		expected: reflect.TypeOf((*interface{})(nil)).Elem(),
+	},
+	{
+		name:     "nested in interface",
+		input:    "d1:ali99999999999999999999999eee",
+		target:   new(interface{}),
+		expected: reflect.TypeOf((*interface{})(nil)).Elem(),
+	},
+	{
+		name:     "slice of interface",
+		input:    "li99999999999999999999999ee",
+		target:   new([]interface{}),
+		expected: reflect.TypeOf((*interface{})(nil)).Elem(),
+	},
+	{
+		name:     "Value",
+		input:    "i99999999999999999999999e",
+		target:   new(Value),
+		expected: valueType,
+	},
+	{
+		name:     "Integer",
+		input:    "i99999999999999999999999e",
+		target:   new(Integer),
+		expected: integerType,
+	},
+	{
+		name:     "List element",
+		input:    "li99999999999999999999999ee",
+		target:   new(List),
+		expected: valueType,
+	},
+}
+
+func TestUnmarshalIntegerOutOfRange(t *testing.T) {
+	for _, tc := range integerRangeTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Unmarshal([]byte(tc.input), tc.target)
+
+			var te *TypeError
+			if !errors.As(err, &te) {
+				t.Fatalf("expected TypeError, got %v", err)
+			}
+			if te.Type != tc.expected {
+				t.Fatalf("expected type %v, got %v", tc.expected, te.Type)
+			}
+			if strings.Contains(err.Error(), "UseBigInt") {
+				t.Fatalf("expected no reference to UseBigInt, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMarshalUnmarshalBigInt(t *testing.T) {
+	type bigStruct struct {
+		Size  *big.Int
+		Value big.Int
+	}
+
+	input := bigStruct{
+		Size:  bigIntFromString("-340282366920938463463374607431768211456"),
+		Value: *big.NewInt(12),
+	}
+	expected := "d4:Sizei-340282366920938463463374607431768211456e5:Valuei12ee"
+
+	buf, err := Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expected %q got %q\n", expected, buf)
+	}
+
+	var got bigStruct
+	if err := Unmarshal(buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Size.Cmp(input.Size) != 0 || got.Value.Cmp(&input.Value) != 0 {
+		t.Fatalf("expected %v got %v\n", input, got)
+	}
+}
+
+func TestUnmarshalBigIntMismatch(t *testing.T) {
+	var i big.Int
+	if err := Unmarshal([]byte("4:test"), &i); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected error %v, got %v", ErrTypeMismatch, err)
+	}
+}
+
+func TestBigIntegerLimit(t *testing.T) {
+	input := "i" + strings.Repeat("9", 64) + "e"
+
+	dec := NewDecoder(strings.NewReader(input))
+	dec.UseBigInt()
+	dec.SetLimits(Limits{MaxBigIntegerLength: 32})
+
+	var v interface{}
+	if err := dec.Decode(&v); !errors.Is(err, strconv.ErrRange) {
+		t.Fatalf("expected error %v, got %v", strconv.ErrRange, err)
+	}
+}
@@ -0,0 +1,910 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Unmarshal parses the bencode-encoded data and stores the result
+// in the value pointed to by v. If v is nil or not a pointer,
+// Unmarshal returns ErrInvalidTarget.
+//
+// Unmarshal uses the inverse of the encodings that Marshal uses,
+// allocating maps, slices, and pointers as necessary, with the
+// following additional rules:
+//
+// To unmarshal a bencode dict into a struct, Unmarshal matches incoming
+// keys to the keys used by Marshal (either the struct field name or its
+// tag). Keys without a corresponding struct field are ignored.
+//
+// To unmarshal bencode into an empty interface value, Unmarshal stores
+// the same Go values returned by the Value methods: int64 for integers,
+// string for bytestrings, []interface{} for lists and
+// map[string]interface{} for dicts.
+//
+// To unmarshal bencode into a value implementing the Unmarshaler
+// interface, Unmarshal calls its UnmarshalBencode method with the verbatim
+// encoding of the bencode value. RawMessage implements Unmarshaler to
+// store that encoding.
+//
+// To unmarshal bencode into a Value, Unmarshal stores the Integer,
+// ByteString, List or Dict matching the bencode type.
+//
+// If a bencode value is not appropriate for a given target type, or if
+// a bencode integer overflows the target type, Unmarshal stops and returns
+// an error wrapping ErrTypeMismatch.
+//
+// Unmarshal enforces DefaultLimits on its input.
+func Unmarshal(data []byte, v interface{}) error {
+	bb := bytes.NewBuffer(data)
+	ds := newDecodeState(bb)
+
+	if err := ds.checkInputSize(int64(len(data))); err != nil {
+		return err
+	}
+	if err := ds.unmarshal(v); err != nil {
+		return err
+	}
+	if bb.Len() > 0 {
+		return ds.syntaxError(ds.off, "end of input", ErrTrailingData)
+	}
+
+	return nil
+}
+
+// reader is the interface implemented by the byte sources the decoder
+// can read from, such as *bytes.Buffer and *bufio.Reader.
+type reader interface {
+	io.Reader
+	io.ByteScanner
+}
+
+// decodeState decodes bencode data into Go values, reading no more
+// than a single bencode value from its source.
+type decodeState struct {
+	r reader
+	// off is the number of bytes consumed from r
+	off int64
+	// canonical rejects any input that is not in canonical form
+	canonical bool
+	// useBigInt decodes integers not fitting an int64 as BigInteger
+	useBigInt bool
+	// alias, if not nil, is the in-memory input whose bytestrings are
+	// aliased instead of copied
+	alias *bytes.Buffer
+	// capture, if not nil, receives a copy of every byte consumed from r
+	capture *bytes.Buffer
+	// path holds the location of the value being decoded
+	path []pathElem
+
+	// limits bounds the resources used by a top-level value
+	limits Limits
+	// start is the offset of the current top-level value
+	start int64
+	// depth is the current nesting depth of lists and dicts
+	depth int
+	// elements is the number of values read in the current top-level value
+	elements int
+}
+
+func newDecodeState(r reader) *decodeState {
+	return &decodeState{
+		r:      r,
+		limits: DefaultLimits,
+	}
+}
+
+func (ds *decodeState) unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrInvalidTarget
+	}
+
+	return ds.value(rv.Elem())
+}
+
+func (ds *decodeState) readByte() (byte, error) {
+	if err := ds.checkInputSize(1); err != nil {
+		return 0, err
+	}
+	c, err := ds.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	ds.off++
+	if ds.capture != nil {
+		ds.capture.WriteByte(c)
+	}
+
+	return c, nil
+}
+
+func (ds *decodeState) unreadByte() error {
+	if err := ds.r.UnreadByte(); err != nil {
+		return err
+	}
+	ds.off--
+	if ds.capture != nil {
+		ds.capture.Truncate(ds.capture.Len() - 1)
+	}
+
+	return nil
+}
+
+func (ds *decodeState) peek() (byte, error) {
+	c, err := ds.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if err := ds.r.UnreadByte(); err != nil {
+		return 0, err
+	}
+
+	return c, nil
+}
+
+// errLiteralTooLong is returned by readLiteral when the
+// delimiter is not found within the maximum length.
+var errLiteralTooLong = errors.New("literal too long")
+
+// readLiteral reads until the first occurrence of delim in the input,
+// returning the data before the delimiter. At most max bytes are read
+// before the delimiter, unless max is negative.
+func (ds *decodeState) readLiteral(delim byte, max int) ([]byte, error) {
+	var buf []byte
+
+	for {
+		c, err := ds.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == delim {
+			return buf, nil
+		}
+		if max >= 0 && len(buf) == max {
+			return nil, errLiteralTooLong
+		}
+		buf = append(buf, c)
+	}
+}
+
+// integerLiteral reads a bencode integer and returns its literal,
+// without delimiters, after checking it against the canonical form.
+// The literal can be at most max bytes long, or unbounded if max is
+// negative.
+func (ds *decodeState) integerLiteral(max int) ([]byte, error) {
+	off := ds.off
+	if err := ds.count(off); err != nil {
+		return nil, err
+	}
+
+	start, err := ds.readByte()
+	if err != nil {
+		return nil, ds.syntaxError(ds.off, "integer", err)
+	}
+	if start != IntegerStart {
+		return nil, ds.syntaxError(off, "integer", ErrWrongStartByte)
+	}
+	literal, err := ds.readLiteral(IntegerEnd, max)
+	if err == errLiteralTooLong {
+		err = fmt.Errorf("integer literal longer than %d bytes: %w", max, strconv.ErrRange)
+		return nil, ds.syntaxError(off, "integer", err)
+	}
+	if err != nil {
+		return nil, ds.syntaxError(ds.off, "integer end", err)
+	}
+
+	if err := ds.checkInteger(literal); err != nil {
+		return nil, ds.syntaxError(off, "integer", err)
+	}
+
+	return literal, nil
+}
+
+// readFull reads exactly len(buf) bytes into buf.
+func (ds *decodeState) readFull(buf []byte) error {
+	n, err := io.ReadFull(ds.r, buf)
+	ds.off += int64(n)
+	if ds.capture != nil {
+		ds.capture.Write(buf[:n])
+	}
+
+	return err
+}
+
+// readN reads exactly n bytes. Memory is allocated as data arrives, so a
+// bogus length prefix cannot force a large allocation on a short input.
+func (ds *decodeState) readN(n int) ([]byte, error) {
+	const chunk = 64 << 10
+
+	if err := ds.checkInputSize(int64(n)); err != nil {
+		return nil, err
+	}
+
+	if n <= chunk {
+		buf := make([]byte, n)
+		return buf, ds.readFull(buf)
+	}
+
+	buf := make([]byte, 0, chunk)
+	for len(buf) < n {
+		step := n - len(buf)
+		if step > cap(buf) {
+			step = cap(buf)
+		}
+		buf = append(buf, make([]byte, step)...)
+		if err := ds.readFull(buf[len(buf)-step:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, nil
+}
+
+// discard skips the next n bytes of the input.
+func (ds *decodeState) discard(n int64) error {
+	if err := ds.checkInputSize(n); err != nil {
+		return err
+	}
+
+	var w io.Writer = io.Discard
+	if ds.capture != nil {
+		w = ds.capture
+	}
+	copied, err := io.CopyN(w, ds.r, n)
+	ds.off += copied
+
+	return err
+}
+
+// readLength reads the length prefix of a bytestring,
+// including the trailing delimiter.
+func (ds *decodeState) readLength() (int, error) {
+	off := ds.off
+	if err := ds.count(off); err != nil {
+		return 0, err
+	}
+
+	literal, err := ds.readLiteral(ByteStringDelimiter, maxIntegerLength)
+	if err == errLiteralTooLong {
+		return 0, ds.syntaxError(off, "bytestring length", ErrInvalidLength)
+	}
+	if err != nil {
+		return 0, ds.syntaxError(ds.off, "bytestring length", err)
+	}
+
+	if ds.canonical && !isCanonicalLength(literal) {
+		err := fmt.Errorf("%w: %q", ErrNonCanonicalLength, literal)
+		return 0, ds.syntaxError(off, "bytestring length", err)
+	}
+	sz, err := strconv.Atoi(string(literal))
+	if err != nil || sz < 0 {
+		err := fmt.Errorf("%w: %q", ErrInvalidLength, literal)
+		return 0, ds.syntaxError(off, "bytestring length", err)
+	}
+	if err := ds.checkStringLength(off, sz); err != nil {
+		return 0, err
+	}
+
+	return sz, nil
+}
+
+// checkInteger checks the literal of an integer, without its
+// delimiters, against the canonical form.
+func (ds *decodeState) checkInteger(literal []byte) error {
+	if !ds.canonical {
+		return nil
+	}
+
+	digits := literal
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+		if len(digits) == 1 && digits[0] == '0' {
+			return fmt.Errorf("%w: negative zero %q", ErrNonCanonicalInteger, literal)
+		}
+	}
+	if !isCanonicalLength(digits) {
+		return fmt.Errorf("%w: %q", ErrNonCanonicalInteger, literal)
+	}
+
+	return nil
+}
+
+// isCanonicalLength reports whether literal is a non-negative
+// decimal number without sign and leading zeros.
+func isCanonicalLength(literal []byte) bool {
+	if len(literal) == 0 || (literal[0] == '0' && len(literal) > 1) {
+		return false
+	}
+	for _, c := range literal {
+		if !isDigit(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// keyOrder tracks the keys of a dict while it is decoded.
+type keyOrder struct {
+	last string
+	n    int
+}
+
+// checkKey checks that key is strictly greater than the previous key
+// of the same dict, as required by the canonical form.
+func (ds *decodeState) checkKey(keys *keyOrder, key string) error {
+	if !ds.canonical {
+		return nil
+	}
+
+	if keys.n > 0 {
+		switch {
+		case key == keys.last:
+			return fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+		case key < keys.last:
+			return fmt.Errorf("%w: %q after %q", ErrUnsortedKeys, key, keys.last)
+		}
+	}
+	keys.last = key
+	keys.n++
+
+	return nil
+}
+
+// pathElem is an element of the path of a value in a bencode
+// document: either a dict key or, if index is not negative, a list index.
+type pathElem struct {
+	key   string
+	index int
+}
+
+func (ds *decodeState) pushKey(key string) {
+	ds.path = append(ds.path, pathElem{key: key, index: -1})
+}
+
+func (ds *decodeState) pushIndex(i int) {
+	ds.path = append(ds.path, pathElem{index: i})
+}
+
+func (ds *decodeState) popPath() {
+	ds.path = ds.path[:len(ds.path)-1]
+}
+
+// pathString formats the current path, e.g. "info.files[3].length".
+func (ds *decodeState) pathString() string {
+	var sb strings.Builder
+
+	for i, elem := range ds.path {
+		if elem.index >= 0 {
+			sb.WriteString("[" + strconv.Itoa(elem.index) + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(elem.key)
+	}
+
+	return sb.String()
+}
+
+// syntaxError returns a SyntaxError for err at the given offset and at
+// the current path. Errors that already carry that information are
+// returned unchanged.
+func (ds *decodeState) syntaxError(off int64, expected string, err error) error {
+	switch err.(type) {
+	case *SyntaxError, *TypeError, *LimitError:
+		return err
+	}
+
+	return &SyntaxError{
+		Offset:   off,
+		Expected: expected,
+		Path:     ds.pathString(),
+		Err:      err,
+	}
+}
+
+// typeError returns a TypeError for a bencode value, described by
+// value, starting at the given offset.
+func (ds *decodeState) typeError(off int64, value string, t reflect.Type) error {
+	return &TypeError{
+		Offset: off,
+		Path:   ds.pathString(),
+		Value:  value,
+		Type:   t,
+	}
+}
+
+// dictKey reads the next key of a dict, checking its order against the
+// previous keys. It returns a nil key if the end of the dict is reached.
+func (ds *decodeState) dictKey(keys *keyOrder) (*ByteString, error) {
+	off := ds.off
+	cur, err := ds.peek()
+	if err != nil {
+		return nil, ds.syntaxError(off, "dict key or end", err)
+	}
+	if cur == DictEnd {
+		_, err := ds.readByte()
+		return nil, err
+	}
+	if !isDigit(cur) {
+		return nil, ds.syntaxError(off, "dict key", ErrWrongStartByte)
+	}
+
+	key := ByteString{}
+	if err := key.unmarshal(ds); err != nil {
+		return nil, err
+	}
+	if err := ds.checkKey(keys, key.value); err != nil {
+		return nil, ds.syntaxError(off, "dict key", err)
+	}
+
+	return &key, nil
+}
+
+// object decodes the next bencode value into an Integer,
+// a ByteString, a List or a Dict.
+func (ds *decodeState) object() (Value, error) {
+	cur, err := ds.peek()
+	if err != nil {
+		return nil, ds.syntaxError(ds.off, "value", err)
+	}
+
+	switch cur {
+	case IntegerStart:
+		return ds.integerObject()
+	case ListStart:
+		obj := List{}
+		err = obj.unmarshal(ds)
+		return obj, err
+	case DictStart:
+		obj := Dict{}
+		err = obj.unmarshal(ds)
+		return obj, err
+	default:
+		obj := ByteString{}
+		err = obj.unmarshal(ds)
+		return obj, err
+	}
+}
+
+// Unmarshaler is the interface implemented by types that can unmarshal
+// a bencode description of themselves. The input is exactly one valid
+// bencode value. UnmarshalBencode must copy the data if it wishes to
+// retain it after returning.
+type Unmarshaler interface {
+	UnmarshalBencode([]byte) error
+}
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// value decodes the next bencode value into v.
+func (ds *decodeState) value(v reflect.Value) error {
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(unmarshalerType) {
+		buf, err := ds.rawValue()
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(Unmarshaler).UnmarshalBencode(buf)
+	}
+
+	switch v.Type() {
+	case integerType, byteStringType, listType, dictType:
+		off := ds.off
+		obj, err := ds.object()
+		if err != nil {
+			if v.Type() == integerType {
+				return setErrorType(err, integerType)
+			}
+			return err
+		}
+		if reflect.TypeOf(obj) != v.Type() {
+			return ds.typeError(off, obj.Kind().String(), v.Type())
+		}
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	case valueType:
+		obj, err := ds.object()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	case bigIntegerType, bigIntType:
+		off := ds.off
+		cur, err := ds.peek()
+		if err != nil {
+			return ds.syntaxError(off, "value", err)
+		}
+		if cur != IntegerStart {
+			return ds.typeError(off, kindOfStartByte(cur), v.Type())
+		}
+
+		obj := BigInteger{}
+		if err := obj.unmarshal(ds); err != nil {
+			return err
+		}
+		if v.Type() == bigIntType {
+			v.Set(reflect.ValueOf(obj.value).Elem())
+		} else {
+			v.Set(reflect.ValueOf(obj))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return ds.value(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			off := ds.off
+			cur, err := ds.peek()
+			if err != nil {
+				return ds.syntaxError(off, "value", err)
+			}
+			return ds.typeError(off, kindOfStartByte(cur), v.Type())
+		}
+		// the values nested into an interface{}
+		// are decoded into interface{} too
+		value, err := ds.valueInterface()
+		if err != nil {
+			return setErrorType(err, v.Type())
+		}
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	cur, err := ds.peek()
+	if err != nil {
+		return ds.syntaxError(ds.off, "value", err)
+	}
+
+	switch cur {
+	case IntegerStart:
+		return ds.integer(v)
+	case ListStart:
+		return ds.list(v)
+	case DictStart:
+		return ds.dict(v)
+	default:
+		return ds.byteString(v)
+	}
+}
+
+// setErrorType sets the Go type of err to t, if err is a TypeError.
+func setErrorType(err error, t reflect.Type) error {
+	if te, ok := err.(*TypeError); ok {
+		te.Type = t
+	}
+
+	return err
+}
+
+// kindOfStartByte returns the name of the bencode type
+// starting with the byte c.
+func kindOfStartByte(c byte) string {
+	switch c {
+	case IntegerStart:
+		return "integer"
+	case ListStart:
+		return "list"
+	case DictStart:
+		return "dict"
+	default:
+		return "bytestring"
+	}
+}
+
+// valueInterface decodes the next bencode value into its
+// standard Go representation.
+func (ds *decodeState) valueInterface() (interface{}, error) {
+	obj, err := ds.object()
+	if err != nil {
+		return nil, err
+	}
+
+	return nativeValue(obj), nil
+}
+
+// skip consumes the next bencode value without storing it.
+func (ds *decodeState) skip() error {
+	off := ds.off
+	cur, err := ds.peek()
+	if err != nil {
+		return ds.syntaxError(off, "value", err)
+	}
+
+	switch cur {
+	case IntegerStart:
+		obj := BigInteger{}
+		return obj.unmarshal(ds)
+	case ListStart:
+		if err := ds.enter(off); err != nil {
+			return err
+		}
+		if _, err := ds.readByte(); err != nil {
+			return err
+		}
+		for i := 0; ; i++ {
+			next, err := ds.peek()
+			if err != nil {
+				return ds.syntaxError(ds.off, "list element or end", err)
+			}
+			if next == ListEnd {
+				ds.leave()
+				_, err := ds.readByte()
+				return err
+			}
+
+			ds.pushIndex(i)
+			if err := ds.skip(); err != nil {
+				return err
+			}
+			ds.popPath()
+		}
+	case DictStart:
+		if err := ds.enter(off); err != nil {
+			return err
+		}
+		if _, err := ds.readByte(); err != nil {
+			return err
+		}
+		var keys keyOrder
+		for {
+			key, err := ds.dictKey(&keys)
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				ds.leave()
+				return nil
+			}
+
+			ds.pushKey(key.value)
+			if err := ds.skip(); err != nil {
+				return err
+			}
+			ds.popPath()
+		}
+	default:
+		sz, err := ds.readLength()
+		if err != nil {
+			return err
+		}
+		if err := ds.discard(int64(sz)); err != nil {
+			return ds.syntaxError(ds.off, "bytestring", err)
+		}
+		return nil
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (ds *decodeState) integer(v reflect.Value) error {
+	off := ds.off
+	// the longest literals fitting a Go integer, of math.MinInt64 and
+	// math.MaxUint64, are both maxIntegerLength bytes long, but longer
+	// ones are read too, to report them as out of range
+	literal, err := ds.integerLiteral(ds.maxBigIntegerLength())
+	if err != nil {
+		return err
+	}
+	s := string(literal)
+
+	switch v.Kind() {
+	case reflect.Bool:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return ds.integerError(off, s, v.Type(), err)
+		}
+		v.SetBool(i != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return ds.integerError(off, s, v.Type(), err)
+		}
+		if v.OverflowInt(i) {
+			return ds.typeError(off, "integer "+s, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return ds.integerError(off, s, v.Type(), err)
+		}
+		if v.OverflowUint(u) {
+			return ds.typeError(off, "integer "+s, v.Type())
+		}
+		v.SetUint(u)
+	default:
+		return ds.typeError(off, "integer", v.Type())
+	}
+
+	return nil
+}
+
+// integerError returns the error for an integer literal that failed to
+// parse into a value of type t: a type error if the literal is a valid
+// integer out of the range of t, like a negative integer for an unsigned
+// type, or a syntax error otherwise.
+func (ds *decodeState) integerError(off int64, literal string, t reflect.Type, err error) error {
+	if _, ok := new(big.Int).SetString(literal, 10); ok {
+		return ds.typeError(off, "integer "+literal, t)
+	}
+
+	// strconv reports the overflow before the invalid characters
+	// following it, but the literal is not an integer at all
+	var ne *strconv.NumError
+	if errors.As(err, &ne) && ne.Err == strconv.ErrRange {
+		err = &strconv.NumError{Func: ne.Func, Num: ne.Num, Err: strconv.ErrSyntax}
+	}
+
+	return ds.syntaxError(off, "integer", err)
+}
+
+func (ds *decodeState) byteString(v reflect.Value) error {
+	off := ds.off
+	obj := ByteString{}
+	if err := obj.unmarshal(ds); err != nil {
+		return err
+	}
+	s := obj.Value()
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return ds.typeError(off, "bytestring", v.Type())
+		}
+		v.SetBytes([]byte(s))
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return ds.typeError(off, "bytestring", v.Type())
+		}
+		if len(s) != v.Len() {
+			return ds.typeError(off, "bytestring of length "+strconv.Itoa(len(s)), v.Type())
+		}
+		for i := 0; i < len(s); i++ {
+			v.Index(i).SetUint(uint64(s[i]))
+		}
+	default:
+		return ds.typeError(off, "bytestring", v.Type())
+	}
+
+	return nil
+}
+
+func (ds *decodeState) list(v reflect.Value) error {
+	off := ds.off
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return ds.typeError(off, "list", v.Type())
+	}
+
+	if err := ds.enter(off); err != nil {
+		return err
+	}
+	if _, err := ds.readByte(); err != nil {
+		return ds.syntaxError(ds.off, "list", err)
+	}
+
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	}
+
+	i := 0
+	for ; ; i++ {
+		cur, err := ds.peek()
+		if err != nil {
+			return ds.syntaxError(ds.off, "list element or end", err)
+		}
+		if cur == ListEnd {
+			break
+		}
+
+		ds.pushIndex(i)
+		switch {
+		case v.Kind() == reflect.Slice:
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := ds.value(elem); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+		case i < v.Len():
+			if err := ds.value(v.Index(i)); err != nil {
+				return err
+			}
+		default:
+			// array is full, drop the remaining elements
+			if err := ds.skip(); err != nil {
+				return err
+			}
+		}
+		ds.popPath()
+	}
+	ds.leave()
+
+	// like encoding/json, zero the elements of an array
+	// past the ones of the list
+	if v.Kind() == reflect.Array {
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	}
+
+	_, err := ds.readByte()
+	return err
+}
+
+func (ds *decodeState) dict(v reflect.Value) error {
+	var fields map[string]field
+
+	off := ds.off
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return ds.typeError(off, "dict", v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	case reflect.Struct:
+		fields = map[string]field{}
+		for _, f := range cachedTypeFields(v.Type()) {
+			fields[f.name] = f
+		}
+	default:
+		return ds.typeError(off, "dict", v.Type())
+	}
+
+	if err := ds.enter(off); err != nil {
+		return err
+	}
+	if _, err := ds.readByte(); err != nil {
+		return ds.syntaxError(ds.off, "dict", err)
+	}
+
+	var keys keyOrder
+	for {
+		key, err := ds.dictKey(&keys)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			ds.leave()
+			return nil
+		}
+
+		ds.pushKey(key.value)
+		if v.Kind() == reflect.Map {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := ds.value(elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key.value).Convert(v.Type().Key()), elem)
+		} else if f, ok := fields[key.value]; ok {
+			fv, _ := fieldByIndex(v, f.index, true)
+			if err := ds.value(fv); err != nil {
+				return err
+			}
+		} else if err := ds.skip(); err != nil {
+			return err
+		}
+		ds.popPath()
+	}
+}
@@ -0,0 +1,329 @@
+package bencode
+
+import (
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestUnmarshalStruct(t *testing.T) {
+	input := []byte("d8:announce14:http://tracker7:comment1:c9:httpseedsl1:se4:infod6:lengthi10e4:name4:file12:piece lengthi4e6:pieces3:xyze7:privatei1e7:unknownli1eee")
+
+	expected := marshalTorrent{
+		Announce: "http://tracker",
+		Comment:  "c",
+		Private:  true,
+		Seeds:    []string{"s"},
+		Info: marshalInfo{
+			Length:      10,
+			Name:        "file",
+			PieceLength: 4,
+			Pieces:      []byte("xyz"),
+		},
+	}
+
+	var got marshalTorrent
+	if err := Unmarshal(input, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %+v got %+v\n", expected, got)
+	}
+}
+
+var unmarshalTestCases = []struct {
+	name     string
+	input    []byte
+	target   func() interface{}
+	expected interface{}
+}{
+	{
+		name:     "integer",
+		input:    []byte("i-42e"),
+		target:   func() interface{} { return new(int) },
+		expected: -42,
+	},
+	{
+		name:     "unsigned integer",
+		input:    []byte("i200e"),
+		target:   func() interface{} { return new(uint8) },
+		expected: uint8(200),
+	},
+	{
+		name:     "largest unsigned integer",
+		input:    []byte("i18446744073709551615e"),
+		target:   func() interface{} { return new(uint64) },
+		expected: uint64(math.MaxUint64),
+	},
+	{
+		name:     "string",
+		input:    []byte("4:test"),
+		target:   func() interface{} { return new(string) },
+		expected: "test",
+	},
+	{
+		name:     "byte array",
+		input:    []byte("3:abc"),
+		target:   func() interface{} { return new([3]byte) },
+		expected: [3]byte{'a', 'b', 'c'},
+	},
+	{
+		name:     "pointer",
+		input:    []byte("4:test"),
+		target:   func() interface{} { return new(*string) },
+		expected: func() *string { s := "test"; return &s }(),
+	},
+	{
+		name:     "slice",
+		input:    []byte("li1ei2ei3ee"),
+		target:   func() interface{} { return new([]int64) },
+		expected: []int64{1, 2, 3},
+	},
+	{
+		name:     "map",
+		input:    []byte("d1:ai1e1:bi2ee"),
+		target:   func() interface{} { return new(map[string]int) },
+		expected: map[string]int{"a": 1, "b": 2},
+	},
+	{
+		name:   "empty interface",
+		input:  []byte("d1:ali1e1:bee"),
+		target: func() interface{} { return new(interface{}) },
+		expected: map[string]interface{}{
+			"a": []interface{}{int64(1), "b"},
+		},
+	},
+	{
+		name:   "unmarshaler",
+		input:  []byte("d5:peersl6:\x0a\x00\x00\x01\x1a\xe1ee"),
+		target: func() interface{} { return new(map[string][]testPeer) },
+		expected: map[string][]testPeer{
+			"peers": {{IP: [4]byte{10, 0, 0, 1}, Port: 6881}},
+		},
+	},
+	{
+		name:     "pointer to unmarshaler",
+		input:    []byte("6:\x0a\x00\x00\x01\x1a\xe1"),
+		target:   func() interface{} { return new(*testPeer) },
+		expected: &testPeer{IP: [4]byte{10, 0, 0, 1}, Port: 6881},
+	},
+	{
+		name:     "embedded pointer",
+		input:    []byte("d1:ai1e1:b1:xe"),
+		target:   func() interface{} { return new(marshalEmbeddedPointer) },
+		expected: marshalEmbeddedPointer{EmbeddedInfo: &EmbeddedInfo{A: 1}, B: "x"},
+	},
+	{
+		name:     "short list into array",
+		input:    []byte("li1ee"),
+		target:   func() interface{} { return &[3]int{7, 7, 7} },
+		expected: [3]int{1, 0, 0},
+	},
+	{
+		name:     "long list into array",
+		input:    []byte("li1ei2ei3ee"),
+		target:   func() interface{} { return &[2]int{7, 7} },
+		expected: [2]int{1, 2},
+	},
+	{
+		name:     "ambiguous embedded fields",
+		input:    []byte("d1:ai1e4:name1:xe"),
+		target:   func() interface{} { return new(marshalAmbiguous) },
+		expected: marshalAmbiguous{ambiguousA: ambiguousA{A: 1}},
+	},
+	{
+		name:   "bencode types",
+		input:  []byte("d4:listl1:aee"),
+		target: func() interface{} { return new(map[string]List) },
+		expected: map[string]List{
+			"list": {[]Value{ByteString{"a"}}},
+		},
+	},
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, tc := range unmarshalTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := tc.target()
+			if err := Unmarshal(tc.input, target); err != nil {
+				t.Fatal(err)
+			}
+
+			got := reflect.ValueOf(target).Elem().Interface()
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected %v got %v\n", tc.expected, got)
+			}
+		})
+	}
+}
+
+var unmarshalErrorTestCases = []struct {
+	name     string
+	input    []byte
+	target   interface{}
+	expected error
+}{
+	{
+		name:     "non-pointer target",
+		input:    []byte("i1e"),
+		target:   0,
+		expected: ErrInvalidTarget,
+	},
+	{
+		name:     "nil target",
+		input:    []byte("i1e"),
+		target:   nil,
+		expected: ErrInvalidTarget,
+	},
+	{
+		name:     "integer into string",
+		input:    []byte("i1e"),
+		target:   new(string),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "integer overflow",
+		input:    []byte("i300e"),
+		target:   new(int8),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "int64 overflow",
+		input:    []byte("i9223372036854775808e"),
+		target:   new(int64),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "uint64 overflow",
+		input:    []byte("i18446744073709551616e"),
+		target:   new(uint64),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "long literal int64 overflow",
+		input:    []byte("i99999999999999999999999e"),
+		target:   new(int64),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "integer overflow into interface",
+		input:    []byte("i99999999999999999999999e"),
+		target:   new(interface{}),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "integer overflow into Value",
+		input:    []byte("li-99999999999999999999999ee"),
+		target:   new(Value),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "integer overflow into Integer",
+		input:    []byte("i9223372036854775808e"),
+		target:   new(Integer),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "malformed long integer",
+		input:    []byte("i9999999999999999999999x9e"),
+		target:   new(interface{}),
+		expected: strconv.ErrSyntax,
+	},
+	{
+		name:     "negative unsigned integer",
+		input:    []byte("i-1e"),
+		target:   new(uint),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "list into map",
+		input:    []byte("le"),
+		target:   new(map[string]int),
+		expected: ErrTypeMismatch,
+	},
+	{
+		name:     "trailing data",
+		input:    []byte("i1ei2e"),
+		target:   new(int),
+		expected: ErrTrailingData,
+	},
+	{
+		name:     "negative length",
+		input:    []byte("-1:"),
+		target:   new(string),
+		expected: ErrInvalidLength,
+	},
+	{
+		name:     "missing end byte",
+		input:    []byte("li1e"),
+		target:   new([]int),
+		expected: io.EOF,
+	},
+	{
+		name:     "unmarshaler error",
+		input:    []byte("l3:abce"),
+		target:   new([]testPeer),
+		expected: ErrInvalidLength,
+	},
+}
+
+func TestUnmarshalError(t *testing.T) {
+	for _, tc := range unmarshalErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Unmarshal(tc.input, tc.target)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	input := marshalTorrent{
+		Announce: "http://tracker",
+		Seeds:    []string{"a", "b"},
+		Info: marshalInfo{
+			Length:      1 << 40,
+			Name:        "name",
+			PieceLength: 1 << 18,
+			Pieces:      []byte{0, 1, 2, 'e', ':'},
+		},
+	}
+
+	buf, err := Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got marshalTorrent
+	if err := Unmarshal(buf, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, input) {
+		t.Fatalf("expected %+v got %+v\n", input, got)
+	}
+}
+
+func TestMarshalUnmarshalUint64(t *testing.T) {
+	buf, err := Marshal(uint64(math.MaxUint64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got uint64
+	if err := Unmarshal(buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != math.MaxUint64 {
+		t.Fatalf("expected %d got %d\n", uint64(math.MaxUint64), got)
+	}
+}
@@ -0,0 +1,667 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Marshal returns the bencode encoding of v.
+//
+// Marshal traverses the value v recursively, using the following
+// type-dependent encodings:
+//
+// Integer, ByteString, List and Dict values encode as themselves.
+//
+// Signed and unsigned integer values, BigInteger and big.Int values encode
+// as bencode integers. Boolean values encode as the integers 1 (true) and
+// 0 (false).
+//
+// String values, byte slices and byte arrays encode as bencode bytestrings.
+//
+// Other slice and array values encode as bencode lists.
+//
+// Map values encode as bencode dicts. The map's key type must be a string
+// kind; keys are sorted as raw bytes, as required by the bencode format.
+//
+// Struct values encode as bencode dicts. Each exported struct field becomes
+// a member of the dict, using the field name as the key, unless the field
+// is omitted for one of the reasons given below.
+//
+// The encoding of each struct field can be customized by the format string
+// stored under the "bencode" key in the struct field's tag. The format
+// string gives the name of the field, possibly followed by a comma-separated
+// list of options. The name may be empty in order to specify options
+// without overriding the default field name. The only supported option is
+// "omitempty", that omits the field if it has an empty value: false, 0, a
+// nil pointer, a nil interface value, and any empty array, slice, map, or
+// string. As a special case, if the field tag is "-", the field is always
+// omitted.
+//
+// Anonymous struct fields, and anonymous pointers to structs, are treated
+// as if their inner exported fields were fields in the outer struct. The
+// fields of a nil embedded pointer are omitted.
+//
+// If a value implements the Marshaler interface, Marshal calls its
+// MarshalBencode method and writes its output, that must be exactly one
+// valid bencode value. RawMessage values implement Marshaler, to encode
+// as their verbatim content.
+//
+// Pointer and interface values encode as the value pointed to or contained.
+// Since bencode has no null value, nil pointers and interfaces are omitted
+// when they are struct fields or map values, and cause Marshal to return
+// ErrNilValue anywhere else.
+//
+// Bencode cannot represent cyclic data structures: a value referring
+// back to itself through a pointer, a map or a slice causes Marshal to
+// return ErrCyclicValue.
+//
+// Any other type causes Marshal to return ErrUnknownType.
+func Marshal(v interface{}) ([]byte, error) {
+	var bb bytes.Buffer
+
+	e := newEncodeState(&bb)
+	if err := e.marshal(v); err != nil {
+		return nil, err
+	}
+
+	return bb.Bytes(), nil
+}
+
+// Marshaler is the interface implemented by types that
+// can marshal themselves into valid bencode.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+// MarshalerError represents an error from calling
+// a MarshalBencode method.
+type MarshalerError struct {
+	Type reflect.Type
+	Err  error
+}
+
+// Error satisfies the error interface.
+func (e *MarshalerError) Error() string {
+	return "bencode: error calling MarshalBencode for type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
+
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+var (
+	integerType    = reflect.TypeOf(Integer{})
+	byteStringType = reflect.TypeOf(ByteString{})
+	listType       = reflect.TypeOf(List{})
+	dictType       = reflect.TypeOf(Dict{})
+)
+
+// writer is the interface used by encodeState to write its output.
+type writer interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// encodeState encodes Go values directly into a writer, keeping track
+// of the number of bytes written and of the first write error.
+type encodeState struct {
+	w writer
+	// bw is the buffered writer introduced by newEncodeState,
+	// if any, that must be flushed at the end of the encoding,
+	// and out is the io.Writer it writes to
+	bw  *bufio.Writer
+	out io.Writer
+	n   int64
+	err error
+
+	// ptrLevel is the number of pointers, maps and slices being
+	// encoded, and ptrSeen holds them once ptrLevel is large enough
+	// for a cycle to be likely
+	ptrLevel uint
+	ptrSeen  map[ptrKey]struct{}
+}
+
+// startDetectingCyclesAfter is the nesting level of pointers, maps and
+// slices after which encodeState starts to track them, so that the
+// common case of shallow values does not pay for the cycle detection.
+const startDetectingCyclesAfter = 1000
+
+// ptrKey identifies a pointer, map or slice being encoded. A slice is
+// identified by its length too, since a sub-slice shares its pointer.
+type ptrKey struct {
+	ptr uintptr
+	len int
+}
+
+// newEncodeState returns an encodeState writing to w. If w does not
+// implement writer, its writes are buffered.
+func newEncodeState(w io.Writer) *encodeState {
+	if ww, ok := w.(writer); ok {
+		return &encodeState{w: ww}
+	}
+
+	bw := bufio.NewWriter(w)
+	return &encodeState{w: bw, bw: bw, out: w}
+}
+
+// marshal encodes v, flushes the output and returns the first
+// encoding or write error. On error, the output still buffered is
+// discarded, so that it does not end up ahead of the next value.
+func (e *encodeState) marshal(v interface{}) error {
+	err := e.reflectValue(reflect.ValueOf(v))
+	if err == nil {
+		err = e.flush()
+	}
+	if err != nil {
+		e.reset()
+	}
+
+	return err
+}
+
+// reset discards the buffered output and the write error, if any.
+func (e *encodeState) reset() {
+	e.err = nil
+	if e.bw != nil {
+		e.bw.Reset(e.out)
+	}
+}
+
+// flush writes any buffered data to the underlying io.Writer and
+// returns the first write error.
+func (e *encodeState) flush() error {
+	if e.err == nil && e.bw != nil {
+		e.err = e.bw.Flush()
+	}
+
+	return e.err
+}
+
+// encodeTo writes the encoding of a bencode value to w, returning
+// the number of bytes written and the first error.
+func encodeTo(w io.Writer, v Value) (int64, error) {
+	e := newEncodeState(w)
+	if err := v.encode(e); err != nil {
+		return e.n, err
+	}
+	err := e.flush()
+
+	return e.n, err
+}
+
+// encodeElement encodes an element of a List or a value of a Dict.
+func (e *encodeState) encodeElement(v Value) error {
+	if v == nil {
+		return ErrNilValue
+	}
+
+	return v.encode(e)
+}
+
+func (e *encodeState) putByte(c byte) {
+	if e.err != nil {
+		return
+	}
+	if e.err = e.w.WriteByte(c); e.err == nil {
+		e.n++
+	}
+}
+
+func (e *encodeState) putString(s string) {
+	if e.err != nil {
+		return
+	}
+	n, err := e.w.WriteString(s)
+	e.n += int64(n)
+	e.err = err
+}
+
+func (e *encodeState) put(b []byte) {
+	if e.err != nil {
+		return
+	}
+	n, err := e.w.Write(b)
+	e.n += int64(n)
+	e.err = err
+}
+
+func (e *encodeState) reflectValue(v reflect.Value) error {
+	if !v.IsValid() {
+		return ErrNilValue
+	}
+
+	if m, ok := marshalerFor(v); ok {
+		return e.marshaler(v.Type(), m)
+	}
+
+	switch v.Type() {
+	case integerType, byteStringType, listType, dictType, bigIntegerType:
+		return v.Interface().(Value).encode(e)
+	case bigIntType:
+		i := v.Interface().(big.Int)
+		return BigInteger{&i}.encode(e)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.writeInt(1)
+		} else {
+			e.writeInt(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBytes(v.Bytes())
+			return nil
+		}
+		return e.cycleGuard(v, e.list)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf := make([]byte, v.Len())
+			for i := range buf {
+				buf[i] = byte(v.Index(i).Uint())
+			}
+			e.writeBytes(buf)
+			return nil
+		}
+		return e.list(v)
+	case reflect.Map:
+		return e.cycleGuard(v, e.dict)
+	case reflect.Struct:
+		return e.structure(v)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return ErrNilValue
+		}
+		if v.Kind() == reflect.Interface {
+			return e.reflectValue(v.Elem())
+		}
+		return e.cycleGuard(v, func(v reflect.Value) error {
+			return e.reflectValue(v.Elem())
+		})
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownType, v.Type())
+	}
+
+	return nil
+}
+
+// cycleGuard calls encode with the pointer, map or slice v, returning
+// an error wrapping ErrCyclicValue if v is already being encoded.
+func (e *encodeState) cycleGuard(v reflect.Value, encode func(reflect.Value) error) error {
+	e.ptrLevel++
+	defer func() { e.ptrLevel-- }()
+
+	if e.ptrLevel > startDetectingCyclesAfter {
+		key := ptrKey{ptr: v.Pointer()}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
+		}
+		if _, ok := e.ptrSeen[key]; ok {
+			return fmt.Errorf("%w: %s", ErrCyclicValue, v.Type())
+		}
+		if e.ptrSeen == nil {
+			e.ptrSeen = map[ptrKey]struct{}{}
+		}
+		e.ptrSeen[key] = struct{}{}
+		defer delete(e.ptrSeen, key)
+	}
+
+	return encode(v)
+}
+
+// marshalerFor returns the Marshaler implemented by v, or by a pointer
+// to v if v is addressable. Nil pointers and interfaces are not
+// Marshalers, since they have no encoding.
+func marshalerFor(v reflect.Value) (Marshaler, bool) {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, false
+	}
+	if v.Type().Implements(marshalerType) {
+		return v.Interface().(Marshaler), true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		return v.Addr().Interface().(Marshaler), true
+	}
+
+	return nil, false
+}
+
+// marshaler writes the output of the MarshalBencode method of m,
+// after checking that it is exactly one valid bencode value.
+func (e *encodeState) marshaler(t reflect.Type, m Marshaler) error {
+	buf, err := m.MarshalBencode()
+	if err == nil {
+		err = checkValid(buf)
+	}
+	if err != nil {
+		return &MarshalerError{Type: t, Err: err}
+	}
+	e.put(buf)
+
+	return nil
+}
+
+func (e *encodeState) writeInt(i int64) {
+	e.putByte(IntegerStart)
+	e.putString(strconv.FormatInt(i, 10))
+	e.putByte(IntegerEnd)
+}
+
+func (e *encodeState) writeUint(u uint64) {
+	e.putByte(IntegerStart)
+	e.putString(strconv.FormatUint(u, 10))
+	e.putByte(IntegerEnd)
+}
+
+func (e *encodeState) writeString(s string) {
+	e.putString(strconv.Itoa(len(s)))
+	e.putByte(ByteStringDelimiter)
+	e.putString(s)
+}
+
+func (e *encodeState) writeBytes(b []byte) {
+	e.putString(strconv.Itoa(len(b)))
+	e.putByte(ByteStringDelimiter)
+	e.put(b)
+}
+
+func (e *encodeState) list(v reflect.Value) error {
+	e.putByte(ListStart)
+	for i := 0; i < v.Len(); i++ {
+		if err := e.reflectValue(v.Index(i)); err != nil {
+			return err
+		}
+		if e.err != nil {
+			return e.err
+		}
+	}
+	e.putByte(ListEnd)
+
+	return nil
+}
+
+func (e *encodeState) dict(v reflect.Value) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("%w: %s", ErrUnknownType, v.Type())
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	e.putByte(DictStart)
+	for _, k := range keys {
+		value := v.MapIndex(k)
+		if isNil(value) {
+			continue
+		}
+		e.writeString(k.String())
+		if err := e.reflectValue(value); err != nil {
+			return err
+		}
+		if e.err != nil {
+			return e.err
+		}
+	}
+	e.putByte(DictEnd)
+
+	return nil
+}
+
+func (e *encodeState) structure(v reflect.Value) error {
+	e.putByte(DictStart)
+	for _, f := range cachedTypeFields(v.Type()) {
+		value, ok := fieldByIndex(v, f.index, false)
+		if !ok || isNil(value) || (f.omitEmpty && isEmptyValue(value)) {
+			continue
+		}
+		e.writeString(f.name)
+		if err := e.reflectValue(value); err != nil {
+			return err
+		}
+		if e.err != nil {
+			return e.err
+		}
+	}
+	e.putByte(DictEnd)
+
+	return nil
+}
+
+// fieldByIndex returns the field of the struct v with the given index,
+// following pointers to embedded structs. If alloc is true, nil pointers
+// are set to newly allocated structs, otherwise fieldByIndex reports
+// false if it finds one.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	if v.Type() == rawMessageType {
+		return v.Len() == 0
+	}
+	return false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// field describes a single struct field mapped to a dict key.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	// tagged reports whether the name comes from the field tag
+	tagged bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
+func cachedTypeFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+// typeFields returns the fields that should be recognized for the given
+// struct type, sorted by key. As in encoding/json, the struct and the
+// structs embedded in it are visited breadth first, and when more fields
+// have the same name the one with the shortest index wins, provided that
+// it is the only one at that depth or the only tagged one. Otherwise the
+// name is ambiguous and all of the fields are dropped.
+func typeFields(t reflect.Type) []field {
+	var fields []field
+
+	next := []field{{}}
+	// visited holds the struct types already traversed, so that
+	// embedded pointers cannot expand a struct forever
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+
+		// a struct type embedded more than once at the same depth
+		// is traversed once, but its fields are ambiguous
+		types := make([]reflect.Type, len(current))
+		count := map[reflect.Type]int{}
+		for i, f := range current {
+			types[i] = t
+			if len(f.index) > 0 {
+				types[i] = t.FieldByIndex(f.index).Type
+				if types[i].Kind() == reflect.Ptr {
+					types[i] = types[i].Elem()
+				}
+			}
+			count[types[i]]++
+		}
+
+		for n, f := range current {
+			st := types[n]
+			if visited[st] {
+				continue
+			}
+			visited[st] = true
+
+			for i := 0; i < st.NumField(); i++ {
+				sf := st.Field(i)
+
+				tag := sf.Tag.Get("bencode")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+				index := append(append([]int{}, f.index...), i)
+
+				if sf.Anonymous && name == "" {
+					ft := sf.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						// pointers to unexported struct types cannot be
+						// allocated while decoding, so they are ignored
+						if sf.Type.Kind() != reflect.Ptr || sf.PkgPath == "" {
+							next = append(next, field{index: index})
+						}
+						continue
+					}
+				}
+				if sf.PkgPath != "" {
+					// unexported field
+					continue
+				}
+
+				tagged := name != ""
+				if name == "" {
+					name = sf.Name
+				}
+				tf := field{
+					name:      name,
+					index:     index,
+					omitEmpty: opts.contains("omitempty"),
+					tagged:    tagged,
+				}
+				fields = append(fields, tf)
+				if count[st] > 1 {
+					fields = append(fields, tf)
+				}
+			}
+		}
+	}
+
+	// sort by name, then by depth, then by tagged first, so that the
+	// fields of each name start with the dominant one
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tagged && !fields[j].tagged
+	})
+
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if f, ok := dominantField(fields[i:j]); ok {
+			out = append(out, f)
+		}
+		i = j
+	}
+
+	return out
+}
+
+// dominantField returns the field that wins among the fields with the
+// same name, sorted as in typeFields, and false if none of them does.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) &&
+		fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+
+	return fields[0], true
+}
+
+// tagOptions is the comma-separated list of options
+// following the name in a struct field's bencode tag.
+type tagOptions string
+
+// contains reports whether the options include the given one.
+func (o tagOptions) contains(option string) bool {
+	if o == "" {
+		return false
+	}
+	for _, opt := range strings.Split(string(o), ",") {
+		if opt == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTag splits a struct field's bencode tag into its name and options.
+func parseTag(tag string) (string, tagOptions) {
+	if idx := strings.Index(tag, ","); idx != -1 {
+		return tag[:idx], tagOptions(tag[idx+1:])
+	}
+	return tag, ""
+}
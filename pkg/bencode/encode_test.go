@@ -0,0 +1,376 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type marshalInfo struct {
+	Length      int64  `bencode:"length"`
+	Name        string `bencode:"name"`
+	PieceLength int64  `bencode:"piece length"`
+	Pieces      []byte `bencode:"pieces"`
+}
+
+type marshalTorrent struct {
+	Announce string      `bencode:"announce"`
+	Comment  string      `bencode:"comment,omitempty"`
+	Private  bool        `bencode:"private,omitempty"`
+	Seeds    []string    `bencode:"httpseeds,omitempty"`
+	Info     marshalInfo `bencode:"info"`
+	Ignored  string      `bencode:"-"`
+	ignored  string
+}
+
+type marshalEmbedded struct {
+	marshalInfo
+	Name string `bencode:"name"`
+}
+
+// EmbeddedInfo is exported, since pointers to unexported
+// embedded structs are ignored.
+type EmbeddedInfo struct {
+	A int64 `bencode:"a"`
+}
+
+type marshalEmbeddedPointer struct {
+	*EmbeddedInfo
+	B string `bencode:"b"`
+}
+
+// RecursiveInfo embeds a pointer to its own exported type.
+type RecursiveInfo struct {
+	*RecursiveInfo
+	A int64 `bencode:"a"`
+}
+
+type ambiguousA struct {
+	Name string `bencode:"name"`
+	A    int64  `bencode:"a"`
+}
+
+type ambiguousB struct {
+	Name string `bencode:"name"`
+	B    int64  `bencode:"b"`
+}
+
+// marshalAmbiguous embeds two structs with a field of the same
+// name at the same depth, so that neither of them is encoded.
+type marshalAmbiguous struct {
+	ambiguousA
+	ambiguousB
+}
+
+type untaggedName struct {
+	Name string
+}
+
+type taggedName struct {
+	Label string `bencode:"Name"`
+}
+
+// marshalTaggedWins embeds two structs with a field named Name at
+// the same depth: the tagged one wins over the untagged one.
+type marshalTaggedWins struct {
+	untaggedName
+	taggedName
+}
+
+type marshalTagOptions struct {
+	A int64 `bencode:"a,omitempty,other"`
+	B int64 `bencode:",other,omitempty"`
+	C int64 `bencode:"c,other"`
+}
+
+// testNode is a linked list node, that can refer back to itself.
+type testNode struct {
+	Next *testNode `bencode:"next,omitempty"`
+}
+
+// testPeer implements Marshaler and Unmarshaler using
+// the compact peer format of tracker responses.
+type testPeer struct {
+	IP   [4]byte
+	Port uint16
+}
+
+func (p testPeer) MarshalBencode() ([]byte, error) {
+	buf := append(p.IP[:], byte(p.Port>>8), byte(p.Port))
+	return Marshal(buf)
+}
+
+func (p *testPeer) UnmarshalBencode(data []byte) error {
+	var buf []byte
+	if err := Unmarshal(data, &buf); err != nil {
+		return err
+	}
+	if len(buf) != 6 {
+		return ErrInvalidLength
+	}
+
+	copy(p.IP[:], buf)
+	p.Port = uint16(buf[4])<<8 | uint16(buf[5])
+
+	return nil
+}
+
+// invalidMarshaler returns more than one bencode value.
+type invalidMarshaler struct{}
+
+func (invalidMarshaler) MarshalBencode() ([]byte, error) {
+	return []byte("i1ei2e"), nil
+}
+
+var marshalTestCases = []struct {
+	name     string
+	input    interface{}
+	expected string
+}{
+	{
+		name:     "integer",
+		input:    -42,
+		expected: "i-42e",
+	},
+	{
+		name:     "unsigned integer",
+		input:    uint8(200),
+		expected: "i200e",
+	},
+	{
+		name:     "boolean",
+		input:    true,
+		expected: "i1e",
+	},
+	{
+		name:     "string",
+		input:    "test",
+		expected: "4:test",
+	},
+	{
+		name:     "byte slice",
+		input:    []byte{'a', 'b'},
+		expected: "2:ab",
+	},
+	{
+		name:     "byte array",
+		input:    [3]byte{'a', 'b', 'c'},
+		expected: "3:abc",
+	},
+	{
+		name:     "slice",
+		input:    []interface{}{1, "two", []int{3}},
+		expected: "li1e3:twoli3eee",
+	},
+	{
+		name:     "map with sorted keys",
+		input:    map[string]int{"b": 2, "a": 1, "c": 3},
+		expected: "d1:ai1e1:bi2e1:ci3ee",
+	},
+	{
+		name:     "map with nil value",
+		input:    map[string]interface{}{"a": nil, "b": 1},
+		expected: "d1:bi1ee",
+	},
+	{
+		name: "struct with tags",
+		input: marshalTorrent{
+			Announce: "http://tracker",
+			Info: marshalInfo{
+				Length:      10,
+				Name:        "file",
+				PieceLength: 4,
+				Pieces:      []byte("xyz"),
+			},
+			Ignored: "ignored",
+			ignored: "ignored",
+		},
+		expected: "d8:announce14:http://tracker4:infod6:lengthi10e4:name4:file12:piece lengthi4e6:pieces3:xyzee",
+	},
+	{
+		name: "pointer to struct",
+		input: &marshalTorrent{
+			Announce: "a",
+			Comment:  "c",
+			Private:  true,
+			Seeds:    []string{"s"},
+		},
+		expected: "d8:announce1:a7:comment1:c9:httpseedsl1:se4:infod6:lengthi0e4:name0:12:piece lengthi0e6:pieces0:e7:privatei1ee",
+	},
+	{
+		name: "embedded struct",
+		input: marshalEmbedded{
+			marshalInfo: marshalInfo{Length: 1, Name: "inner"},
+			Name:        "outer",
+		},
+		expected: "d6:lengthi1e4:name5:outer12:piece lengthi0e6:pieces0:e",
+	},
+	{
+		name: "embedded pointer",
+		input: marshalEmbeddedPointer{
+			EmbeddedInfo: &EmbeddedInfo{A: 1},
+			B:            "x",
+		},
+		expected: "d1:ai1e1:b1:xe",
+	},
+	{
+		name:     "nil embedded pointer",
+		input:    marshalEmbeddedPointer{B: "x"},
+		expected: "d1:b1:xe",
+	},
+	{
+		name:     "recursive embedded pointer",
+		input:    RecursiveInfo{RecursiveInfo: &RecursiveInfo{A: 2}, A: 1},
+		expected: "d1:ai1ee",
+	},
+	{
+		name:     "ambiguous embedded fields",
+		input:    marshalAmbiguous{ambiguousA{Name: "a", A: 1}, ambiguousB{Name: "b", B: 2}},
+		expected: "d1:ai1e1:bi2ee",
+	},
+	{
+		name:     "tagged embedded field wins",
+		input:    marshalTaggedWins{untaggedName{Name: "untagged"}, taggedName{Label: "tagged"}},
+		expected: "d4:Name6:taggede",
+	},
+	{
+		name:     "multiple tag options",
+		input:    marshalTagOptions{},
+		expected: "d1:ci0ee",
+	},
+	{
+		name: "nil Marshaler struct field",
+		input: struct {
+			A Marshaler `bencode:"a"`
+			B int       `bencode:"b"`
+		}{B: 1},
+		expected: "d1:bi1ee",
+	},
+	{
+		name: "bencode types",
+		input: map[string]interface{}{
+			"dict": Dict{map[ByteString]Value{{"one"}: Integer{1}}},
+			"list": List{[]Value{ByteString{"a"}}},
+		},
+		expected: "d4:dictd3:onei1ee4:listl1:aee",
+	},
+}
+
+func TestMarshal(t *testing.T) {
+	for _, tc := range marshalTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Marshal(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+
+			if !bytes.Equal(got, []byte(tc.expected)) {
+				t.Fatalf("expected %q got %q\n", tc.expected, got)
+			}
+		})
+	}
+}
+
+var marshalErrorTestCases = []struct {
+	name     string
+	input    interface{}
+	expected error
+}{
+	{
+		name:     "invalid marshaler output",
+		input:    []invalidMarshaler{{}},
+		expected: ErrTrailingData,
+	},
+	{
+		name:     "nil value",
+		input:    nil,
+		expected: ErrNilValue,
+	},
+	{
+		name:     "nil list element",
+		input:    []interface{}{nil},
+		expected: ErrNilValue,
+	},
+	{
+		name:     "nil Marshaler list element",
+		input:    []Marshaler{nil},
+		expected: ErrNilValue,
+	},
+	{
+		name: "nil Marshaler in a list field",
+		input: struct {
+			A []Marshaler `bencode:"a"`
+		}{A: []Marshaler{testPeer{}, nil}},
+		expected: ErrNilValue,
+	},
+	{
+		name:     "float value",
+		input:    1.5,
+		expected: ErrUnknownType,
+	},
+	{
+		name:     "map with non-string keys",
+		input:    map[int]string{1: "one"},
+		expected: ErrUnknownType,
+	},
+	{
+		name:     "cyclic pointer",
+		input:    func() interface{} { n := &testNode{}; n.Next = n; return n }(),
+		expected: ErrCyclicValue,
+	},
+	{
+		name:     "cyclic map",
+		input:    func() interface{} { m := map[string]interface{}{}; m["m"] = m; return m }(),
+		expected: ErrCyclicValue,
+	},
+	{
+		name:     "cyclic slice",
+		input:    func() interface{} { s := []interface{}{nil}; s[0] = s; return s }(),
+		expected: ErrCyclicValue,
+	},
+}
+
+func TestMarshaler(t *testing.T) {
+	input := map[string]interface{}{
+		"peer":  testPeer{IP: [4]byte{10, 0, 0, 1}, Port: 6881},
+		"peers": []*testPeer{{IP: [4]byte{127, 0, 0, 1}, Port: 80}},
+	}
+	expected := "d4:peer6:\x0a\x00\x00\x01\x1a\xe15:peersl6:\x7f\x00\x00\x01\x00\x50ee"
+
+	got, err := Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, []byte(expected)) {
+		t.Fatalf("expected %q got %q\n", expected, got)
+	}
+}
+
+func TestMarshalerError(t *testing.T) {
+	_, err := Marshal(invalidMarshaler{})
+
+	var me *MarshalerError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected error of type %T, got %v", me, err)
+	}
+	if me.Type != reflect.TypeOf(invalidMarshaler{}) {
+		t.Fatalf("expected type %v got %v\n", reflect.TypeOf(invalidMarshaler{}), me.Type)
+	}
+}
+
+func TestMarshalError(t *testing.T) {
+	for _, tc := range marshalErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Marshal(tc.input)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
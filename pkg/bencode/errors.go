@@ -0,0 +1,84 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A SyntaxError describes malformed bencode data, reporting where in
+// the input the problem was found. The underlying error, such as
+// ErrWrongStartByte or io.EOF, can be matched with errors.Is.
+type SyntaxError struct {
+	// Offset is the byte offset in the input where the error occurred.
+	Offset int64
+	// Expected describes what the decoder expected to read,
+	// e.g. "integer" or "dict key".
+	Expected string
+	// Path is the location of the malformed value in the document,
+	// e.g. "info.files[3].length", or empty for the top-level value.
+	Path string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bencode: syntax error at offset %d%s: expected %s: %v", e.Offset, formatPath(e.Path), e.Expected, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// A TypeError describes a bencode value that was not appropriate for
+// the Go value it had to be stored into. It matches ErrTypeMismatch
+// with errors.Is.
+type TypeError struct {
+	// Offset is the byte offset in the input of the bencode value.
+	Offset int64
+	// Path is the location of the value in the document,
+	// e.g. "info.files[3].length", or empty for the top-level value.
+	Path string
+	// Value describes the bencode value, e.g. "integer" or "list".
+	Value string
+	// Type is the type of the Go value it could not be assigned to.
+	Type reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("bencode: cannot decode %s into Go value of type %s at offset %d%s", e.Value, e.Type, e.Offset, formatPath(e.Path))
+}
+
+// Unwrap returns ErrTypeMismatch.
+func (e *TypeError) Unwrap() error {
+	return ErrTypeMismatch
+}
+
+func formatPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " (" + path + ")"
+}
+
+// A LimitError describes a bencode value exceeding one of the decoder
+// Limits. The underlying error, such as ErrStringTooLong or ErrMaxDepth,
+// can be matched with errors.Is.
+type LimitError struct {
+	// Offset is the byte offset in the input where the limit was exceeded.
+	Offset int64
+	// Path is the location of the offending value in the document,
+	// e.g. "info.files[3].path", or empty for the top-level value.
+	Path string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("bencode: limit exceeded at offset %d%s: %v", e.Offset, formatPath(e.Path), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,130 @@
+package bencode
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type errorsFile struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+}
+
+type errorsInfo struct {
+	Files []errorsFile `bencode:"files"`
+}
+
+type errorsTorrent struct {
+	Info errorsInfo `bencode:"info"`
+}
+
+var syntaxErrorTestCases = []struct {
+	name     string
+	input    string
+	offset   int64
+	path     string
+	expected error
+}{
+	{
+		name:     "wrong dict key",
+		input:    "d1:ai1ei2ee",
+		offset:   7,
+		path:     "",
+		expected: ErrWrongStartByte,
+	},
+	{
+		name:     "invalid integer in list",
+		input:    "li1ei1xe",
+		offset:   4,
+		path:     "[1]",
+		expected: nil,
+	},
+	{
+		name:     "missing end byte",
+		input:    "d1:ali1e",
+		offset:   8,
+		path:     "a",
+		expected: io.EOF,
+	},
+	{
+		name:     "nested path",
+		input:    "d4:infod5:filesld6:lengthi1eed6:lengthi-x",
+		offset:   41,
+		path:     "info.files[1].length",
+		expected: io.EOF,
+	},
+}
+
+func TestSyntaxError(t *testing.T) {
+	for _, tc := range syntaxErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			err := Unmarshal([]byte(tc.input), &v)
+
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("expected SyntaxError, got %v", err)
+			}
+			if se.Offset != tc.offset {
+				t.Fatalf("expected offset %d, got %d", tc.offset, se.Offset)
+			}
+			if se.Path != tc.path {
+				t.Fatalf("expected path %q, got %q", tc.path, se.Path)
+			}
+			if tc.expected != nil && !errors.Is(err, tc.expected) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestTypeError(t *testing.T) {
+	input := "d4:infod5:filesld6:lengthi1eed6:length1:xeeee"
+
+	var got errorsTorrent
+	err := Unmarshal([]byte(input), &got)
+
+	var te *TypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected TypeError, got %v", err)
+	}
+	if te.Offset != 38 {
+		t.Fatalf("expected offset %d, got %d", 38, te.Offset)
+	}
+	if te.Path != "info.files[1].length" {
+		t.Fatalf("expected path %q, got %q", "info.files[1].length", te.Path)
+	}
+	if te.Value != "bytestring" {
+		t.Fatalf("expected value %q, got %q", "bytestring", te.Value)
+	}
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected error %v, got %v", ErrTypeMismatch, err)
+	}
+
+	expected := "bencode: cannot decode bytestring into Go value of type int64 at offset 38 (info.files[1].length)"
+	if err.Error() != expected {
+		t.Fatalf("expected message %q, got %q", expected, err.Error())
+	}
+}
+
+func TestDecoderTokenErrorPath(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("d4:listli1ei+xeee"))
+
+	var err error
+	for err == nil {
+		_, err = dec.Token()
+	}
+
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected SyntaxError, got %v", err)
+	}
+	if se.Path != "list[1]" {
+		t.Fatalf("expected path %q, got %q", "list[1]", se.Path)
+	}
+	if se.Offset != 11 {
+		t.Fatalf("expected offset %d, got %d", 11, se.Offset)
+	}
+}
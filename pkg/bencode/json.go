@@ -0,0 +1,278 @@
+package bencode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// jsonBytesKey is the member of the JSON object representing
+	// a bytestring that is not valid UTF-8.
+	jsonBytesKey = "$bytes"
+	// jsonBytesKeyPrefix is the prefix of JSON object keys representing
+	// a dict key that is not valid UTF-8.
+	jsonBytesKeyPrefix = "$bytes:"
+)
+
+// MarshalJSON satisfies the json.Marshaler interface to marshal
+// an Integer as a JSON number.
+func (i Integer) MarshalJSON() ([]byte, error) {
+	return marshalJSON(i)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface to marshal
+// a BigInteger as a JSON number.
+func (i BigInteger) MarshalJSON() ([]byte, error) {
+	return marshalJSON(i)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface to marshal
+// a ByteString as a JSON string, or as a "$bytes" object if it is
+// not valid UTF-8.
+func (bs ByteString) MarshalJSON() ([]byte, error) {
+	return marshalJSON(bs)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface to marshal
+// a List as a JSON array. See ParseJSON for the details of the JSON
+// representation.
+func (l List) MarshalJSON() ([]byte, error) {
+	return marshalJSON(l)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface to marshal
+// a Dict as a JSON object. See ParseJSON for the details of the JSON
+// representation.
+func (d Dict) MarshalJSON() ([]byte, error) {
+	return marshalJSON(d)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface to unmarshal
+// a List from its JSON representation.
+func (l *List) UnmarshalJSON(data []byte) error {
+	v, err := ParseJSON(data)
+	if err != nil {
+		return err
+	}
+
+	list, ok := v.AsList()
+	if !ok {
+		return fmt.Errorf("%w: cannot unmarshal JSON %s into List", ErrTypeMismatch, v.Kind())
+	}
+	*l = list
+
+	return nil
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface to unmarshal
+// a Dict from its JSON representation.
+func (d *Dict) UnmarshalJSON(data []byte) error {
+	v, err := ParseJSON(data)
+	if err != nil {
+		return err
+	}
+
+	dict, ok := v.AsDict()
+	if !ok {
+		return fmt.Errorf("%w: cannot unmarshal JSON %s into Dict", ErrTypeMismatch, v.Kind())
+	}
+	*d = dict
+
+	return nil
+}
+
+func marshalJSON(v Value) ([]byte, error) {
+	var bb bytes.Buffer
+
+	if err := writeJSON(&bb, v); err != nil {
+		return nil, err
+	}
+
+	return bb.Bytes(), nil
+}
+
+// writeJSON writes the JSON representation of v to bb.
+func writeJSON(bb *bytes.Buffer, v Value) error {
+	switch value := v.(type) {
+	case Integer:
+		bb.WriteString(strconv.FormatInt(value.value, 10))
+	case BigInteger:
+		bb.WriteString(value.Value().String())
+	case ByteString:
+		if !utf8.ValidString(value.value) {
+			bb.WriteString(`{"` + jsonBytesKey + `":"`)
+			bb.WriteString(base64.StdEncoding.EncodeToString([]byte(value.value)))
+			bb.WriteString(`"}`)
+			return nil
+		}
+		writeJSONString(bb, value.value)
+	case List:
+		bb.WriteByte('[')
+		for i, elem := range value.value {
+			if i > 0 {
+				bb.WriteByte(',')
+			}
+			if err := writeJSON(bb, elem); err != nil {
+				return err
+			}
+		}
+		bb.WriteByte(']')
+	case Dict:
+		bb.WriteByte('{')
+		for i, k := range value.Keys() {
+			if i > 0 {
+				bb.WriteByte(',')
+			}
+			writeJSONString(bb, jsonKey(k))
+			bb.WriteByte(':')
+			if err := writeJSON(bb, value.value[ByteString{k}]); err != nil {
+				return err
+			}
+		}
+		bb.WriteByte('}')
+	default:
+		return ErrNilValue
+	}
+
+	return nil
+}
+
+// writeJSONString writes s, that must be valid UTF-8,
+// as a JSON string to bb.
+func writeJSONString(bb *bytes.Buffer, s string) {
+	buf, _ := json.Marshal(s)
+	bb.Write(buf)
+}
+
+// jsonKey returns the JSON object key representing the dict key k.
+func jsonKey(k string) string {
+	switch {
+	case !utf8.ValidString(k):
+		return jsonBytesKeyPrefix + base64.StdEncoding.EncodeToString([]byte(k))
+	case strings.HasPrefix(k, "$"):
+		return "$" + k
+	default:
+		return k
+	}
+}
+
+// ParseJSON returns the bencode value represented by the JSON data.
+//
+// The JSON representation of bencode values, used by ParseJSON and by
+// the MarshalJSON methods, is lossless and reversible:
+//
+// Integers and big integers are JSON numbers.
+//
+// Bytestrings holding valid UTF-8 text are JSON strings. Other bytestrings
+// are JSON objects with the single member "$bytes", holding the standard
+// base64 encoding of the bytes, e.g. {"$bytes":"3q2+7w=="}.
+//
+// Lists are JSON arrays.
+//
+// Dicts are JSON objects, with their members sorted by key. Keys holding
+// valid UTF-8 text are used as they are, but a leading "$" is doubled,
+// so that "$" prefixed keys are reserved to the representation itself.
+// Other keys are written as "$bytes:" followed by the standard base64
+// encoding of the key.
+//
+// Canonical bencode input survives a round trip through JSON byte for
+// byte.
+//
+// ParseJSON returns an error wrapping ErrInvalidJSON if data is valid
+// JSON that does not represent a bencode value, e.g. if it holds a
+// null, a boolean or a number that is not an integer.
+func ParseJSON(data []byte) (Value, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, ErrTrailingData
+	}
+
+	return fromJSON(v)
+}
+
+// fromJSON converts a JSON value decoded into an interface{},
+// with numbers decoded as json.Number, into a bencode value.
+func fromJSON(v interface{}) (Value, error) {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			return Integer{i}, nil
+		}
+		i, ok := new(big.Int).SetString(string(value), 10)
+		if !ok {
+			return nil, fmt.Errorf("%w: number %s is not an integer", ErrInvalidJSON, value)
+		}
+		return BigInteger{i}, nil
+	case string:
+		return ByteString{value}, nil
+	case []interface{}:
+		l := List{make([]Value, 0, len(value))}
+		for _, elem := range value {
+			obj, err := fromJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			l.value = append(l.value, obj)
+		}
+		return l, nil
+	case map[string]interface{}:
+		if b, ok := value[jsonBytesKey]; ok && len(value) == 1 {
+			s, ok := b.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %q member is not a string", ErrInvalidJSON, jsonBytesKey)
+			}
+			buf, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+			}
+			return ByteString{string(buf)}, nil
+		}
+
+		d := Dict{make(map[ByteString]Value, len(value))}
+		for k, elem := range value {
+			key, err := fromJSONKey(k)
+			if err != nil {
+				return nil, err
+			}
+			obj, err := fromJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			d.value[ByteString{key}] = obj
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected JSON value %v", ErrInvalidJSON, v)
+	}
+}
+
+// fromJSONKey returns the dict key represented by the JSON object key k.
+func fromJSONKey(k string) (string, error) {
+	switch {
+	case strings.HasPrefix(k, "$$"):
+		return k[1:], nil
+	case strings.HasPrefix(k, jsonBytesKeyPrefix):
+		buf, err := base64.StdEncoding.DecodeString(k[len(jsonBytesKeyPrefix):])
+		if err != nil {
+			return "", fmt.Errorf("%w: key %q: %v", ErrInvalidJSON, k, err)
+		}
+		return string(buf), nil
+	case strings.HasPrefix(k, "$"):
+		return "", fmt.Errorf("%w: reserved key %q", ErrInvalidJSON, k)
+	default:
+		return k, nil
+	}
+}
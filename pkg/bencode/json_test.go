@@ -0,0 +1,145 @@
+package bencode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var jsonTestCases = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{
+		name:     "text",
+		input:    "l4:testi-3ee",
+		expected: `["test",-3]`,
+	},
+	{
+		name:     "binary bytestring",
+		input:    "l4:\xde\xad\xbe\xefe",
+		expected: `[{"$bytes":"3q2+7w=="}]`,
+	},
+	{
+		name:     "numeric bytestring",
+		input:    "l2:42i42ee",
+		expected: `["42",42]`,
+	},
+	{
+		name:     "big integer",
+		input:    "li18446744073709551616ee",
+		expected: `[18446744073709551616]`,
+	},
+	{
+		name:     "dollar keys",
+		input:    "d0:de6:$bytes1:a2:$x1:b1:ylee",
+		expected: `{"":{},"$$bytes":"a","$$x":"b","y":[]}`,
+	},
+	{
+		name:     "binary key",
+		input:    "d2:\xff\xfei1ee",
+		expected: `{"$bytes://4=":1}`,
+	},
+}
+
+func TestJSON(t *testing.T) {
+	for _, tc := range jsonTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewDecoder(bytes.NewReader([]byte(tc.input)))
+			dec.UseBigInt()
+
+			var v Value
+			if err := dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			buf, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(buf) != tc.expected {
+				t.Fatalf("expected %s got %s\n", tc.expected, buf)
+			}
+
+			got, err := ParseJSON(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotBuf, err := Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(gotBuf) != tc.input {
+				t.Fatalf("expected %q got %q\n", tc.input, gotBuf)
+			}
+		})
+	}
+}
+
+func TestDictJSONRoundTrip(t *testing.T) {
+	input := []byte("d8:announce3:url4:infod6:lengthi10e6:pieces3:\x00\x01\xffee")
+
+	var d Dict
+	if err := d.UnmarshalBinary(input); err != nil {
+		t.Fatal(err)
+	}
+
+	type wrapper struct {
+		Torrent Dict `json:"torrent"`
+	}
+
+	buf, err := json.Marshal(wrapper{d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"torrent":{"announce":"url","info":{"length":10,"pieces":{"$bytes":"AAH/"}}}}`
+	if string(buf) != expected {
+		t.Fatalf("expected %s got %s\n", expected, buf)
+	}
+
+	var got wrapper
+	if err := json.Unmarshal(buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	output, err := got.Torrent.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output, input) {
+		t.Fatalf("expected %q got %q\n", input, output)
+	}
+}
+
+var parseJSONErrorTestCases = []struct {
+	name     string
+	input    string
+	expected error
+}{
+	{"null", `null`, ErrInvalidJSON},
+	{"boolean", `[true]`, ErrInvalidJSON},
+	{"float", `1.5`, ErrInvalidJSON},
+	{"reserved key", `{"$x":1}`, ErrInvalidJSON},
+	{"invalid base64", `{"$bytes":"!"}`, ErrInvalidJSON},
+	{"invalid base64 key", `{"$bytes:!":1}`, ErrInvalidJSON},
+	{"trailing data", `1 2`, ErrTrailingData},
+}
+
+func TestParseJSONError(t *testing.T) {
+	for _, tc := range parseJSONErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseJSON([]byte(tc.input)); !errors.Is(err, tc.expected) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestDictUnmarshalJSONMismatch(t *testing.T) {
+	var d Dict
+	if err := json.Unmarshal([]byte(`[1]`), &d); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected error %v, got %v", ErrTypeMismatch, err)
+	}
+}
@@ -0,0 +1,100 @@
+package bencode
+
+// Limits bounds the resources used to decode a single top-level bencode
+// value, to make decoding safe on untrusted input. A zero field disables
+// the corresponding limit.
+type Limits struct {
+	// MaxStringLength is the maximum length of a single bytestring.
+	MaxStringLength int
+	// MaxBigIntegerLength is the maximum length of the literal of an
+	// integer that does not fit an int64. Parsing such integers takes
+	// more than linear time in their length.
+	MaxBigIntegerLength int
+	// MaxDepth is the maximum nesting depth of lists and dicts.
+	MaxDepth int
+	// MaxElements is the maximum number of values, including dict keys
+	// and nested values, that make up a top-level value.
+	MaxElements int
+	// MaxInputSize is the maximum encoded size in bytes of a
+	// top-level value.
+	MaxInputSize int64
+}
+
+// DefaultLimits are the limits used by Unmarshal, by the UnmarshalBinary
+// methods and by every new Decoder. They are large enough for any
+// real-world .torrent file, while preventing a small malicious input from
+// exhausting memory or overflowing the stack. The encoded size of a value
+// is not limited by default, because streams of values can be arbitrarily
+// large.
+var DefaultLimits = Limits{
+	MaxStringLength:     128 << 20,
+	MaxBigIntegerLength: 4096,
+	MaxDepth:            512,
+	MaxElements:         1 << 24,
+}
+
+// reset prepares the decodeState for a new top-level value.
+func (ds *decodeState) reset() {
+	ds.path = ds.path[:0]
+	ds.depth = 0
+	ds.elements = 0
+	ds.start = ds.off
+}
+
+// count records that a new value starts at offset off.
+func (ds *decodeState) count(off int64) error {
+	ds.elements++
+	if ds.limits.MaxElements > 0 && ds.elements > ds.limits.MaxElements {
+		return ds.limitError(off, ErrTooManyElements)
+	}
+
+	return nil
+}
+
+// enter records that a new list or dict starts at offset off.
+func (ds *decodeState) enter(off int64) error {
+	if err := ds.count(off); err != nil {
+		return err
+	}
+
+	ds.depth++
+	if ds.limits.MaxDepth > 0 && ds.depth > ds.limits.MaxDepth {
+		return ds.limitError(off, ErrMaxDepth)
+	}
+
+	return nil
+}
+
+// leave records that the innermost list or dict has ended.
+func (ds *decodeState) leave() {
+	ds.depth--
+}
+
+// checkStringLength checks the length of a bytestring starting at
+// offset off.
+func (ds *decodeState) checkStringLength(off int64, n int) error {
+	if ds.limits.MaxStringLength > 0 && n > ds.limits.MaxStringLength {
+		return ds.limitError(off, ErrStringTooLong)
+	}
+
+	return nil
+}
+
+// checkInputSize checks that n more bytes can be read from the input.
+func (ds *decodeState) checkInputSize(n int64) error {
+	if ds.limits.MaxInputSize > 0 && ds.off-ds.start+n > ds.limits.MaxInputSize {
+		return ds.limitError(ds.off, ErrInputTooLarge)
+	}
+
+	return nil
+}
+
+// limitError returns a LimitError for err at the given offset and at
+// the current path.
+func (ds *decodeState) limitError(off int64, err error) error {
+	return &LimitError{
+		Offset: off,
+		Path:   ds.pathString(),
+		Err:    err,
+	}
+}
@@ -0,0 +1,129 @@
+package bencode
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var limitsTestCases = []struct {
+	name     string
+	input    string
+	limits   Limits
+	expected error
+}{
+	{
+		name:     "string too long",
+		input:    "5:hello",
+		limits:   Limits{MaxStringLength: 4},
+		expected: ErrStringTooLong,
+	},
+	{
+		name:     "dict key too long",
+		input:    "d5:helloi1ee",
+		limits:   Limits{MaxStringLength: 4},
+		expected: ErrStringTooLong,
+	},
+	{
+		name:     "nesting too deep",
+		input:    "llllee" + "ee",
+		limits:   Limits{MaxDepth: 3},
+		expected: ErrMaxDepth,
+	},
+	{
+		name:     "too many elements",
+		input:    "li1ei2ei3ee",
+		limits:   Limits{MaxElements: 3},
+		expected: ErrTooManyElements,
+	},
+	{
+		name:     "input too large",
+		input:    "l4:spam4:eggse",
+		limits:   Limits{MaxInputSize: 10},
+		expected: ErrInputTooLarge,
+	},
+	{
+		name:     "default nesting limit",
+		input:    strings.Repeat("l", 1000) + strings.Repeat("e", 1000),
+		limits:   DefaultLimits,
+		expected: ErrMaxDepth,
+	},
+	{
+		name:     "default string limit",
+		input:    "1000000000:abc",
+		limits:   DefaultLimits,
+		expected: ErrStringTooLong,
+	},
+}
+
+func TestDecoderLimits(t *testing.T) {
+	for _, tc := range limitsTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decodeDec := NewDecoder(strings.NewReader(tc.input))
+			decodeDec.SetLimits(tc.limits)
+
+			var v interface{}
+			err := decodeDec.Decode(&v)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Decode: expected error %v, got %v", tc.expected, err)
+			}
+
+			var le *LimitError
+			if !errors.As(err, &le) {
+				t.Fatalf("Decode: expected LimitError, got %v", err)
+			}
+
+			tokenDec := NewDecoder(strings.NewReader(tc.input))
+			tokenDec.SetLimits(tc.limits)
+			for err = nil; err == nil; {
+				_, err = tokenDec.Token()
+			}
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Token: expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestDecoderLimitsPerValue(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("li1ei2eeli3ei4ee"))
+	dec.SetLimits(Limits{MaxElements: 3, MaxInputSize: 8})
+
+	for i := 0; i < 2; i++ {
+		var v []int
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestByteStringBogusLength(t *testing.T) {
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+	var s string
+	err := Unmarshal([]byte("100000000:abc"), &s)
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected error %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("expected less than 1 MiB allocated, got %d bytes", allocated)
+	}
+}
+
+func TestIntegerLiteralTooLong(t *testing.T) {
+	var i int64
+	err := Unmarshal([]byte("i"+strings.Repeat("1", DefaultLimits.MaxBigIntegerLength+1)+"e"), &i)
+
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected SyntaxError, got %v", err)
+	}
+	if se.Offset != 0 {
+		t.Fatalf("expected offset %d, got %d", 0, se.Offset)
+	}
+}
@@ -0,0 +1,173 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// queryStep is a single step of a compiled query expression.
+type queryStep struct {
+	// key is the dict key selected by the step
+	key string
+	// index is the list index selected by the step, if isIndex is true
+	index   int
+	isIndex bool
+	// wildcard selects every element of a list or every value of a dict
+	wildcard bool
+}
+
+// Query returns the values inside v selected by the path expression expr.
+//
+// A path expression is a sequence of steps, each applied to the values
+// selected by the previous ones:
+//
+//	key       selects the value of a dict key; keys can contain spaces,
+//	          e.g. "info.piece length"
+//	.key      as above, after the first step
+//	["key"]   as above, for keys containing '.', '[' or ']'; the key is
+//	          a Go quoted string
+//	[n]       selects the element n of a list, counting from the end
+//	          of the list if n is negative
+//	*, [*]    selects every element of a list or every value of a dict,
+//	          in key order
+//
+// For example, "info.files[*].path" selects the path of every file of a
+// multi-file torrent. The empty expression selects v itself.
+//
+// Steps that do not match, like a missing key, an index out of range or
+// a key applied to a list, select nothing: Query returns an error only
+// if expr is not a valid path expression, wrapping ErrInvalidQuery.
+func Query(v Value, expr string) ([]Value, error) {
+	steps, err := compileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	values := []Value{v}
+	for _, step := range steps {
+		var next []Value
+		for _, value := range values {
+			next = step.apply(next, value)
+		}
+		values = next
+	}
+
+	return values, nil
+}
+
+// apply appends to selected the values inside v selected by the step.
+func (s queryStep) apply(selected []Value, v Value) []Value {
+	switch value := v.(type) {
+	case List:
+		switch {
+		case s.wildcard:
+			return append(selected, value.value...)
+		case s.isIndex:
+			i := s.index
+			if i < 0 {
+				i += len(value.value)
+			}
+			if i >= 0 && i < len(value.value) {
+				return append(selected, value.value[i])
+			}
+		}
+	case Dict:
+		switch {
+		case s.wildcard:
+			for _, k := range value.Keys() {
+				selected = append(selected, value.value[ByteString{k}])
+			}
+		case !s.isIndex:
+			if elem, ok := value.value[ByteString{s.key}]; ok {
+				return append(selected, elem)
+			}
+		}
+	}
+
+	return selected
+}
+
+// compileQuery parses a path expression into its steps.
+func compileQuery(expr string) ([]queryStep, error) {
+	var steps []queryStep
+
+	for i := 0; i < len(expr); {
+		switch {
+		case expr[i] == '[':
+			step, n, err := compileBracket(expr[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v at offset %d", ErrInvalidQuery, err, i)
+			}
+			steps = append(steps, step)
+			i += n
+			continue
+		case expr[i] == '.' && len(steps) > 0 && i == len(expr)-1:
+			return nil, fmt.Errorf("%w: trailing '.' at offset %d", ErrInvalidQuery, i)
+		case expr[i] == '.' && len(steps) > 0:
+			i++
+		case expr[i] == '.':
+			return nil, fmt.Errorf("%w: unexpected '.' at offset %d", ErrInvalidQuery, i)
+		case len(steps) > 0:
+			return nil, fmt.Errorf("%w: expected '.' or '[' at offset %d", ErrInvalidQuery, i)
+		}
+
+		end := strings.IndexAny(expr[i:], ".[]")
+		if end == -1 {
+			end = len(expr) - i
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("%w: empty key at offset %d", ErrInvalidQuery, i)
+		}
+
+		key := expr[i : i+end]
+		if key == "*" {
+			steps = append(steps, queryStep{wildcard: true})
+		} else {
+			steps = append(steps, queryStep{key: key})
+		}
+		i += end
+	}
+
+	return steps, nil
+}
+
+// compileBracket parses a bracketed step at the start of expr,
+// returning the step and its length.
+func compileBracket(expr string) (queryStep, int, error) {
+	if strings.HasPrefix(expr, `["`) {
+		// find the closing quote, skipping escaped characters
+		n := 2
+		for n < len(expr) && expr[n] != '"' {
+			if expr[n] == '\\' {
+				n++
+			}
+			n++
+		}
+		if n+1 >= len(expr) || expr[n+1] != ']' {
+			return queryStep{}, 0, errors.New("missing '\"]'")
+		}
+		key, err := strconv.Unquote(expr[1 : n+1])
+		if err != nil {
+			return queryStep{}, 0, errors.New("invalid quoted key")
+		}
+		return queryStep{key: key}, n + 2, nil
+	}
+
+	end := strings.IndexByte(expr, ']')
+	if end == -1 {
+		return queryStep{}, 0, errors.New("missing ']'")
+	}
+
+	inner := expr[1:end]
+	if inner == "*" {
+		return queryStep{wildcard: true}, end + 1, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return queryStep{}, 0, fmt.Errorf("invalid index %q", inner)
+	}
+
+	return queryStep{index: index, isIndex: true}, end + 1, nil
+}
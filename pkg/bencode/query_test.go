@@ -0,0 +1,128 @@
+package bencode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var queryTestInput = []byte("d8:announce3:url4:infod5:filesld6:lengthi1e4:pathl1:a1:beed6:lengthi2e4:pathl1:ceee12:piece lengthi16e8:with.dot1:xee")
+
+var queryTestCases = []struct {
+	name     string
+	expr     string
+	expected []Value
+}{
+	{
+		name:     "empty expression",
+		expr:     "",
+		expected: nil,
+	},
+	{
+		name:     "key",
+		expr:     "announce",
+		expected: []Value{ByteString{"url"}},
+	},
+	{
+		name:     "key with spaces",
+		expr:     "info.piece length",
+		expected: []Value{Integer{16}},
+	},
+	{
+		name:     "quoted key",
+		expr:     `info["with.dot"]`,
+		expected: []Value{ByteString{"x"}},
+	},
+	{
+		name:     "index",
+		expr:     "info.files[1].length",
+		expected: []Value{Integer{2}},
+	},
+	{
+		name:     "negative index",
+		expr:     "info.files[0].path[-1]",
+		expected: []Value{ByteString{"b"}},
+	},
+	{
+		name: "wildcard",
+		expr: "info.files[*].path[*]",
+		expected: []Value{
+			ByteString{"a"},
+			ByteString{"b"},
+			ByteString{"c"},
+		},
+	},
+	{
+		name: "dict wildcard",
+		expr: "info.files[*].*",
+		expected: []Value{
+			Integer{1},
+			List{[]Value{ByteString{"a"}, ByteString{"b"}}},
+			Integer{2},
+			List{[]Value{ByteString{"c"}}},
+		},
+	},
+	{
+		name:     "missing key",
+		expr:     "info.missing",
+		expected: nil,
+	},
+	{
+		name:     "index out of range",
+		expr:     "info.files[2]",
+		expected: nil,
+	},
+	{
+		name:     "key on a list",
+		expr:     "info.files.length",
+		expected: nil,
+	},
+}
+
+func TestQuery(t *testing.T) {
+	var root Value
+	if err := Unmarshal(queryTestInput, &root); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range queryTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Query(root, tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := tc.expected
+			if tc.expr == "" {
+				expected = []Value{root}
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("expected %v got %v\n", expected, got)
+			}
+		})
+	}
+}
+
+var queryErrorTestCases = []struct {
+	name string
+	expr string
+}{
+	{"leading dot", ".info"},
+	{"empty key", "info..name"},
+	{"trailing dot", "info."},
+	{"trailing dot after index", "info.files[0]."},
+	{"unterminated bracket", "info.files[0"},
+	{"invalid index", "info.files[a]"},
+	{"unterminated quoted key", `info["name]`},
+	{"missing dot", "info.files[0]length"},
+}
+
+func TestQueryError(t *testing.T) {
+	for _, tc := range queryErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Query(Dict{}, tc.expr); !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("expected error %v, got %v", ErrInvalidQuery, err)
+			}
+		})
+	}
+}
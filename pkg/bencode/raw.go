@@ -0,0 +1,72 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// RawMessage is a raw encoded bencode value.
+//
+// When decoding, a RawMessage captures the verbatim bytes of a value,
+// including any nested list or dict, without interpreting them. When
+// encoding, the bytes are written back unchanged. It can be used to delay
+// bencode decoding or to precompute a bencode encoding, and to preserve
+// the exact original form of a value, e.g. to hash it.
+//
+// An empty RawMessage has no valid encoding: it is omitted when it is a
+// struct field or a map value, and causes Marshal to return ErrNilValue
+// anywhere else.
+type RawMessage []byte
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+// MarshalBencode satisfies the Marshaler interface
+// returning m as the bencode encoding of m.
+func (m RawMessage) MarshalBencode() ([]byte, error) {
+	if len(m) == 0 {
+		return nil, ErrNilValue
+	}
+
+	return m, nil
+}
+
+// UnmarshalBencode satisfies the Unmarshaler interface
+// setting *m to a copy of data.
+func (m *RawMessage) UnmarshalBencode(data []byte) error {
+	*m = append((*m)[0:0], data...)
+
+	return nil
+}
+
+// checkValid returns an error if data is not exactly one
+// valid bencode value.
+func checkValid(data []byte) error {
+	bb := bytes.NewBuffer(data)
+	ds := newDecodeState(bb)
+
+	if err := ds.skip(); err != nil {
+		return err
+	}
+	if bb.Len() > 0 {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// rawValue consumes the next bencode value and returns its
+// verbatim encoding.
+func (ds *decodeState) rawValue() ([]byte, error) {
+	var buf bytes.Buffer
+
+	prev := ds.capture
+	ds.capture = &buf
+	err := ds.skip()
+	ds.capture = prev
+
+	if prev != nil {
+		prev.Write(buf.Bytes())
+	}
+
+	return buf.Bytes(), err
+}
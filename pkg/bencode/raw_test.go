@@ -0,0 +1,101 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type rawTorrent struct {
+	Announce string     `bencode:"announce"`
+	Info     RawMessage `bencode:"info"`
+}
+
+func TestRawMessageRoundTrip(t *testing.T) {
+	// the info dict has non-canonical content that a re-encoding
+	// would not preserve
+	info := "d6:lengthi010e4:name4:file1:ali1e2:xxee"
+	input := []byte("d8:announce1:a4:info" + info + "e")
+
+	var got rawTorrent
+	if err := Unmarshal(input, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got.Info, []byte(info)) {
+		t.Fatalf("expected %q got %q\n", info, got.Info)
+	}
+
+	buf, err := Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf, input) {
+		t.Fatalf("expected %q got %q\n", input, buf)
+	}
+}
+
+func TestRawMessageMap(t *testing.T) {
+	input := []byte("d1:ai1e1:bl1:xe1:cd1:yi2eee")
+
+	var got map[string]RawMessage
+	if err := Unmarshal(input, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"a": "i1e",
+		"b": "l1:xe",
+		"c": "d1:yi2ee",
+	}
+	for k, v := range expected {
+		if !bytes.Equal(got[k], []byte(v)) {
+			t.Fatalf("expected %q got %q for key %q\n", v, got[k], k)
+		}
+	}
+}
+
+func TestRawMessageOmitEmpty(t *testing.T) {
+	buf, err := Marshal(rawTorrent{Announce: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf, []byte("d8:announce1:ae")) {
+		t.Fatalf("expected %q got %q\n", "d8:announce1:ae", buf)
+	}
+}
+
+var rawMessageMarshalErrorTestCases = []struct {
+	name     string
+	input    RawMessage
+	expected error
+}{
+	{
+		name:     "empty message",
+		input:    RawMessage{},
+		expected: ErrNilValue,
+	},
+	{
+		name:     "trailing data",
+		input:    RawMessage("i1ei2e"),
+		expected: ErrTrailingData,
+	},
+	{
+		name:     "wrong start byte",
+		input:    RawMessage("d1:ai1ei2ee"),
+		expected: ErrWrongStartByte,
+	},
+}
+
+func TestRawMessageMarshalError(t *testing.T) {
+	for _, tc := range rawMessageMarshalErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Marshal(tc.input)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
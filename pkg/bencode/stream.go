@@ -0,0 +1,342 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Encoder writes bencode values to an output stream.
+//
+// The Encoder writes the encoding of each value directly to the stream,
+// as it traverses the value, without building it in memory first. If the
+// stream does not implement io.ByteWriter and io.StringWriter, like
+// *bufio.Writer and *bytes.Buffer do, the Encoder introduces its own
+// buffering and flushes it at the end of each call to Encode.
+type Encoder struct {
+	e *encodeState
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{newEncodeState(w)}
+}
+
+// Encode writes the bencode encoding of v to the stream.
+//
+// Since the encoding is streamed, if Encode returns an error part of
+// the encoding of v may have already been written. The part still
+// buffered by the Encoder is discarded instead, so it is never written
+// by later calls to Encode.
+//
+// See the documentation for Marshal for details about the
+// conversion of Go values to bencode.
+func (e *Encoder) Encode(v interface{}) error {
+	return e.e.marshal(v)
+}
+
+// A Decoder reads and decodes bencode values from an input stream.
+//
+// The Decoder reads its input incrementally through a buffered reader
+// and consumes exactly one bencode value for each call to Decode, so it
+// can be used to read back-to-back values from a socket or a pipe.
+type Decoder struct {
+	r  *bufio.Reader
+	ds *decodeState
+
+	tokenStack  []tokenFrame
+	tokenOffset int64
+}
+
+// tokenFrame holds the state of a list or dict opened by Token.
+type tokenFrame struct {
+	delim byte
+	// n is the number of values read so far in the list or dict,
+	// counting dict keys as values
+	n int
+	// keys tracks the dict keys read so far
+	keys keyOrder
+	// key is the last dict key read
+	key string
+}
+
+// NewDecoder returns a new decoder that reads from r.
+//
+// If r is not already a *bufio.Reader, the decoder introduces its own
+// buffering and may read data from r beyond the bencode values requested.
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &Decoder{
+		r:  br,
+		ds: newDecodeState(br),
+	}
+}
+
+// Decode reads the next bencode-encoded value from its input and
+// stores it in the value pointed to by v.
+//
+// Decode returns io.EOF when the input ends cleanly before a new value
+// and io.ErrUnexpectedEOF when it ends in the middle of a value.
+//
+// Decode can be mixed with calls to Token to decode a single value
+// nested inside a list or a dict. Dict keys must be read with Token:
+// calling Decode where a key is expected returns ErrNotAtValue.
+//
+// See the documentation for Unmarshal for details about the
+// conversion of bencode into Go values.
+func (d *Decoder) Decode(v interface{}) error {
+	if _, err := d.tokenPrepareValue(false); err != nil {
+		return err
+	}
+
+	d.tokenPushPath()
+	if err := d.ds.unmarshal(v); err != nil {
+		return d.tokenError(err)
+	}
+	d.tokenValueEnd()
+
+	return nil
+}
+
+// DisallowNonCanonical causes the Decoder to return an error when the
+// input is not in canonical form: integers with leading zeros, a plus
+// sign or negative zero, bytestring lengths with leading zeros or a sign,
+// and dicts with unsorted or duplicate keys are all rejected.
+//
+// Canonical input re-encodes to exactly the same bytes, which is
+// required, for example, to compute a correct info-hash.
+func (d *Decoder) DisallowNonCanonical() {
+	d.ds.canonical = true
+}
+
+// UseBigInt causes the Decoder to decode integers that do not fit an
+// int64 as BigInteger values inside a List or Dict and as *big.Int values
+// inside an interface{}, instead of returning an error. Integers that fit
+// an int64 are decoded as usual.
+func (d *Decoder) UseBigInt() {
+	d.ds.useBigInt = true
+}
+
+// SetLimits sets the limits enforced on each top-level value read by
+// the Decoder, replacing DefaultLimits.
+func (d *Decoder) SetLimits(l Limits) {
+	d.ds.limits = l
+}
+
+// Buffered returns a reader of the data remaining in the Decoder's
+// buffer. The reader is valid until the next call to Decode.
+func (d *Decoder) Buffered() io.Reader {
+	buf, _ := d.r.Peek(d.r.Buffered())
+	return bytes.NewReader(buf)
+}
+
+// InputOffset returns the input stream byte offset of the current
+// decoder position, that is the offset of the end of the most recently
+// decoded value or token.
+func (d *Decoder) InputOffset() int64 {
+	return d.ds.off
+}
+
+// A Token holds a value of one of these types:
+//
+//	Delim, for the list and dict start delimiters and for the end delimiter
+//	Integer, for bencode integers
+//	BigInteger, for bencode integers not fitting an int64 if UseBigInt was called
+//	ByteString, for bencode bytestrings
+type Token interface{}
+
+// A Delim is a bencode list or dict delimiter: one of ListStart,
+// DictStart or the end delimiter shared by lists and dicts.
+type Delim byte
+
+// String satisfies the fmt.Stringer interface.
+func (d Delim) String() string {
+	return string(d)
+}
+
+// Token returns the next bencode token in the input stream.
+// At the end of the input stream, Token returns nil, io.EOF.
+//
+// Token guarantees that the delimiters it returns are properly nested
+// and matched and that dict keys are bytestrings: if Token encounters
+// an unexpected delimiter in the input, it returns an error.
+func (d *Decoder) Token() (Token, error) {
+	cur, err := d.tokenPrepareValue(true)
+	if err != nil {
+		return nil, err
+	}
+	d.tokenOffset = d.ds.off
+
+	if cur == ListEnd {
+		if _, err := d.ds.readByte(); err != nil {
+			return nil, d.tokenError(err)
+		}
+		d.tokenStack = d.tokenStack[:len(d.tokenStack)-1]
+		d.ds.leave()
+		d.tokenValueEnd()
+		return Delim(ListEnd), nil
+	}
+
+	top := len(d.tokenStack) - 1
+	isKey := top >= 0 && d.tokenStack[top].delim == DictStart && d.tokenStack[top].n%2 == 0
+	d.tokenPushPath()
+
+	switch cur {
+	case ListStart, DictStart:
+		if err := d.ds.enter(d.tokenOffset); err != nil {
+			return nil, err
+		}
+		if _, err := d.ds.readByte(); err != nil {
+			return nil, d.tokenError(err)
+		}
+		d.tokenStack = append(d.tokenStack, tokenFrame{delim: cur})
+		return Delim(cur), nil
+	case IntegerStart:
+		obj, err := d.ds.integerObject()
+		if err != nil {
+			return nil, d.tokenError(err)
+		}
+		d.tokenValueEnd()
+		return obj, nil
+	default:
+		obj := ByteString{}
+		if err := obj.unmarshal(d.ds); err != nil {
+			return nil, d.tokenError(err)
+		}
+		if isKey {
+			frame := &d.tokenStack[top]
+			if err := d.ds.checkKey(&frame.keys, obj.value); err != nil {
+				return nil, d.ds.syntaxError(d.tokenOffset, "dict key", err)
+			}
+			frame.key = obj.value
+		}
+		d.tokenValueEnd()
+		return obj, nil
+	}
+}
+
+// TokenOffset returns the input stream byte offset of the first byte
+// of the token most recently returned by Token.
+func (d *Decoder) TokenOffset() int64 {
+	return d.tokenOffset
+}
+
+// More reports whether there is another element in the
+// current list or dict being parsed.
+func (d *Decoder) More() bool {
+	c, err := d.ds.peek()
+	return err == nil && c != ListEnd
+}
+
+// Skip consumes the next bencode value, including any nested list or
+// dict, without allocating memory for its contents. It is typically used
+// after reading a dict key with Token, to ignore the corresponding value.
+// Like Decode, Skip returns ErrNotAtValue where a dict key is expected.
+func (d *Decoder) Skip() error {
+	if _, err := d.tokenPrepareValue(false); err != nil {
+		return err
+	}
+
+	d.tokenPushPath()
+	if err := d.ds.skip(); err != nil {
+		return d.tokenError(err)
+	}
+	d.tokenValueEnd()
+
+	return nil
+}
+
+// tokenPrepareValue checks that the next value in the input is valid
+// in the position given by the current token state, and returns its
+// first byte. If allowEnd is true, the next value can also be the end
+// delimiter of the currently open list or dict or a dict key, which
+// only Token can read.
+func (d *Decoder) tokenPrepareValue(allowEnd bool) (byte, error) {
+	if len(d.tokenStack) == 0 {
+		// a new top-level value starts
+		d.ds.reset()
+	}
+
+	cur, err := d.ds.peek()
+	if err != nil {
+		return 0, d.tokenError(err)
+	}
+
+	if len(d.tokenStack) == 0 {
+		if cur == ListEnd {
+			return 0, d.ds.syntaxError(d.ds.off, "value", ErrWrongStartByte)
+		}
+		return cur, nil
+	}
+
+	top := d.tokenStack[len(d.tokenStack)-1]
+	expectKey := top.delim == DictStart && top.n%2 == 0
+	switch {
+	case cur == ListEnd && top.delim == DictStart && !expectKey:
+		return 0, d.ds.syntaxError(d.ds.off, "dict value", ErrWrongStartByte)
+	case cur == ListEnd && !allowEnd:
+		return 0, d.ds.syntaxError(d.ds.off, "value", ErrWrongStartByte)
+	case expectKey && !allowEnd:
+		return 0, d.ds.syntaxError(d.ds.off, "dict value", ErrNotAtValue)
+	case expectKey && cur != ListEnd && !isDigit(cur):
+		return 0, d.ds.syntaxError(d.ds.off, "dict key", ErrWrongStartByte)
+	}
+
+	return cur, nil
+}
+
+// tokenPushPath adds the location of the next value inside the
+// currently open list or dict to the decoding path. Dict keys are not
+// part of the path, so that tokenValueEnd pops exactly what was pushed.
+func (d *Decoder) tokenPushPath() {
+	if len(d.tokenStack) == 0 {
+		return
+	}
+
+	top := d.tokenStack[len(d.tokenStack)-1]
+	if top.delim == DictStart && top.n%2 == 0 {
+		return
+	}
+	if top.delim == DictStart {
+		d.ds.pushKey(top.key)
+	} else {
+		d.ds.pushIndex(top.n)
+	}
+}
+
+// tokenValueEnd records that a complete value has been read.
+func (d *Decoder) tokenValueEnd() {
+	if len(d.tokenStack) == 0 {
+		return
+	}
+
+	top := &d.tokenStack[len(d.tokenStack)-1]
+	if top.delim != DictStart || top.n%2 == 1 {
+		// the value was not a dict key
+		d.ds.popPath()
+	}
+	top.n++
+}
+
+// tokenError converts an end of input in the middle of a value, or in
+// the middle of a list or dict opened by Token, to io.ErrUnexpectedEOF.
+func (d *Decoder) tokenError(err error) error {
+	if err == io.EOF {
+		if len(d.tokenStack) == 0 {
+			return io.EOF
+		}
+		return d.ds.syntaxError(d.ds.off, "list or dict end", io.ErrUnexpectedEOF)
+	}
+
+	var se *SyntaxError
+	if errors.As(err, &se) && se.Err == io.EOF {
+		se.Err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
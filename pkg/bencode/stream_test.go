@@ -0,0 +1,420 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncoderMultipleValues(t *testing.T) {
+	var sb strings.Builder
+	enc := NewEncoder(&sb)
+
+	for _, v := range []interface{}{1, "test", []int{2}, map[string]int{"a": 3}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := "i1e4:testli2eed1:ai3ee"
+	if sb.String() != expected {
+		t.Fatalf("expected %q got %q\n", expected, sb.String())
+	}
+}
+
+// failingWriter fails every write after the first n bytes.
+type failingWriter struct {
+	n int
+}
+
+var errWrite = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	value := List{[]Value{ByteString{strings.Repeat("x", 8192)}, Integer{1}}}
+
+	if err := NewEncoder(&failingWriter{100}).Encode(value); err != errWrite {
+		t.Fatalf("expected error %v, got %v", errWrite, err)
+	}
+	if _, err := value.WriteTo(&failingWriter{100}); err != errWrite {
+		t.Fatalf("expected error %v, got %v", errWrite, err)
+	}
+}
+
+func TestEncoderErrorDiscardsBuffer(t *testing.T) {
+	// hide the io.ByteWriter and io.StringWriter methods of the
+	// buffer, so that the Encoder has to buffer its output
+	var w bytes.Buffer
+	enc := NewEncoder(struct{ io.Writer }{&w})
+
+	if err := enc.Encode([]interface{}{1, 2, nil}); !errors.Is(err, ErrNilValue) {
+		t.Fatalf("expected error %v, got %v", ErrNilValue, err)
+	}
+	if err := enc.Encode(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.String() != "i5e" {
+		t.Fatalf("expected %q got %q\n", "i5e", w.String())
+	}
+}
+
+func TestDecoderMultipleValues(t *testing.T) {
+	input := "i1e4:testli2eed1:ai3ee"
+	expected := []interface{}{
+		int64(1),
+		"test",
+		[]interface{}{int64(2)},
+		map[string]interface{}{"a": int64(3)},
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	for _, want := range expected {
+		var got interface{}
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v got %v\n", want, got)
+		}
+	}
+
+	var got interface{}
+	if err := dec.Decode(&got); err != io.EOF {
+		t.Fatalf("expected error %v, got %v", io.EOF, err)
+	}
+}
+
+func TestDecoderUnexpectedEOF(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("i1eli2e"))
+
+	var i int
+	if err := dec.Decode(&i); err != nil {
+		t.Fatal(err)
+	}
+
+	var l []int
+	if err := dec.Decode(&l); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected error %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestDecoderDoesNotReadAhead(t *testing.T) {
+	pr, pw := io.Pipe()
+	t.Cleanup(func() {
+		pr.Close()
+	})
+
+	go func() {
+		// the writer never closes the pipe: Decode must return as
+		// soon as a complete value has been read
+		pw.Write([]byte("d3:key5:valuee"))
+	}()
+
+	var got map[string]string
+	if err := NewDecoder(pr).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["key"] != "value" {
+		t.Fatalf("expected %q got %q\n", "value", got["key"])
+	}
+}
+
+func TestDecoderBuffered(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("i1etrailing"))
+
+	var i int
+	if err := dec.Decode(&i); err != nil {
+		t.Fatal(err)
+	}
+
+	rest, err := io.ReadAll(dec.Buffered())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rest, []byte("trailing")) {
+		t.Fatalf("expected %q got %q\n", "trailing", rest)
+	}
+}
+
+type tokenWithOffset struct {
+	token  Token
+	offset int64
+}
+
+func TestDecoderToken(t *testing.T) {
+	input := "d4:listli1e2:abe3:numi-5ee"
+	expected := []tokenWithOffset{
+		{Delim(DictStart), 0},
+		{ByteString{"list"}, 1},
+		{Delim(ListStart), 7},
+		{Integer{1}, 8},
+		{ByteString{"ab"}, 11},
+		{Delim(ListEnd), 15},
+		{ByteString{"num"}, 16},
+		{Integer{-5}, 21},
+		{Delim(DictEnd), 25},
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	for _, want := range expected {
+		got, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want.token {
+			t.Fatalf("expected token %v got %v\n", want.token, got)
+		}
+		if dec.TokenOffset() != want.offset {
+			t.Fatalf("expected offset %d got %d\n", want.offset, dec.TokenOffset())
+		}
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		t.Fatalf("expected error %v, got %v", io.EOF, err)
+	}
+}
+
+func TestDecoderTokenSkip(t *testing.T) {
+	input := "d6:piecesd1:ali1e2:xxee4:name4:teste"
+
+	dec := NewDecoder(strings.NewReader(input))
+	if _, err := dec.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	var name string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key != (ByteString{"name"}) {
+			if err := dec.Skip(); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := dec.Decode(&name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	end, err := dec.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if end != Delim(DictEnd) {
+		t.Fatalf("expected token %v got %v\n", Delim(DictEnd), end)
+	}
+	if name != "test" {
+		t.Fatalf("expected %q got %q\n", "test", name)
+	}
+}
+
+func TestDecoderTokenSkipKey(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("d1:cli1e1:xee"))
+	if _, err := dec.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dec.Skip(); !errors.Is(err, ErrNotAtValue) {
+		t.Fatalf("expected error %v, got %v", ErrNotAtValue, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var n int
+	err := dec.Decode(&n)
+	var te *TypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected *TypeError, got %v", err)
+	}
+	if te.Path != "c[1]" {
+		t.Fatalf("expected path %q got %q\n", "c[1]", te.Path)
+	}
+}
+
+var tokenErrorTestCases = []struct {
+	name     string
+	input    string
+	expected error
+}{
+	{
+		name:     "unmatched end",
+		input:    "i1ee",
+		expected: ErrWrongStartByte,
+	},
+	{
+		name:     "integer dict key",
+		input:    "di1ei2ee",
+		expected: ErrWrongStartByte,
+	},
+	{
+		name:     "dict key without value",
+		input:    "d1:ae",
+		expected: ErrWrongStartByte,
+	},
+	{
+		name:     "unterminated list",
+		input:    "li1e",
+		expected: io.ErrUnexpectedEOF,
+	},
+}
+
+func TestDecoderTokenError(t *testing.T) {
+	for _, tc := range tokenErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tc.input))
+
+			var err error
+			for err == nil {
+				_, err = dec.Token()
+			}
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+var nonCanonicalTestCases = []struct {
+	name     string
+	input    string
+	expected error
+}{
+	{
+		name:     "integer with leading zero",
+		input:    "i012e",
+		expected: ErrNonCanonicalInteger,
+	},
+	{
+		name:     "integer with plus sign",
+		input:    "i+5e",
+		expected: ErrNonCanonicalInteger,
+	},
+	{
+		name:     "negative zero",
+		input:    "i-0e",
+		expected: ErrNonCanonicalInteger,
+	},
+	{
+		name:     "length with plus sign",
+		input:    "+4:test",
+		expected: ErrNonCanonicalLength,
+	},
+	{
+		name:     "length with leading zero",
+		input:    "l04:teste",
+		expected: ErrNonCanonicalLength,
+	},
+	{
+		name:     "unsorted keys",
+		input:    "d1:bi1e1:ai2ee",
+		expected: ErrUnsortedKeys,
+	},
+	{
+		name:     "duplicate keys",
+		input:    "d1:ai1e1:ai2ee",
+		expected: ErrDuplicateKey,
+	},
+	{
+		name:     "unsorted keys in nested dict",
+		input:    "ld1:bi1e1:ai2eee",
+		expected: ErrUnsortedKeys,
+	},
+}
+
+func TestDecoderDisallowNonCanonical(t *testing.T) {
+	for _, tc := range nonCanonicalTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			if err := NewDecoder(strings.NewReader(tc.input)).Decode(&v); err != nil {
+				t.Fatalf("unexpected error in default mode: %v", err)
+			}
+
+			decodeDec := NewDecoder(strings.NewReader(tc.input))
+			decodeDec.DisallowNonCanonical()
+			err := decodeDec.Decode(&v)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Decode: expected error %v, got %v", tc.expected, err)
+			}
+
+			skipDec := NewDecoder(strings.NewReader(tc.input))
+			skipDec.DisallowNonCanonical()
+			err = skipDec.Skip()
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Skip: expected error %v, got %v", tc.expected, err)
+			}
+
+			tokenDec := NewDecoder(strings.NewReader(tc.input))
+			tokenDec.DisallowNonCanonical()
+			for err = nil; err == nil; {
+				_, err = tokenDec.Token()
+			}
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Token: expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	t.Run("decode at dict key", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader("d1:bi1e1:ai2ee"))
+		dec.DisallowNonCanonical()
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+
+		var v interface{}
+		if err := dec.Decode(&v); !errors.Is(err, ErrNotAtValue) {
+			t.Fatalf("expected error %v, got %v", ErrNotAtValue, err)
+		}
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		// the next key must still go through the canonical checks
+		if err := dec.Decode(&v); !errors.Is(err, ErrNotAtValue) {
+			t.Fatalf("expected error %v, got %v", ErrNotAtValue, err)
+		}
+		if _, err := dec.Token(); !errors.Is(err, ErrUnsortedKeys) {
+			t.Fatalf("expected error %v, got %v", ErrUnsortedKeys, err)
+		}
+	})
+}
+
+func TestDecoderDisallowNonCanonicalStruct(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("d4:name1:a6:lengthi1ee"))
+	dec.DisallowNonCanonical()
+
+	var info marshalInfo
+	if err := dec.Decode(&info); !errors.Is(err, ErrUnsortedKeys) {
+		t.Fatalf("expected error %v, got %v", ErrUnsortedKeys, err)
+	}
+}
@@ -0,0 +1,174 @@
+package bencode
+
+import (
+	"math/big"
+	"reflect"
+)
+
+// Value is a bencode value: an Integer, a BigInteger, a ByteString,
+// a List or a Dict.
+//
+// The interface is sealed: it cannot be implemented outside this
+// package, so a List or a Dict can only hold valid bencode values.
+type Value interface {
+	// Kind returns the bencode type of the value.
+	Kind() Kind
+	// AsInt returns the value as an int64, and false if the value
+	// is not an integer or does not fit an int64.
+	AsInt() (int64, bool)
+	// AsBigInt returns the value as a *big.Int, and false if the
+	// value is not an integer.
+	AsBigInt() (*big.Int, bool)
+	// AsString returns the value as a string, and false if the
+	// value is not a bytestring.
+	AsString() (string, bool)
+	// AsList returns the value as a List, and false if the value
+	// is not a list.
+	AsList() (List, bool)
+	// AsDict returns the value as a Dict, and false if the value
+	// is not a dict.
+	AsDict() (Dict, bool)
+
+	encode(e *encodeState) error
+}
+
+var valueType = reflect.TypeOf((*Value)(nil)).Elem()
+
+// Kind is the bencode type of a Value.
+type Kind int
+
+// The bencode types.
+const (
+	IntegerKind Kind = iota
+	ByteStringKind
+	ListKind
+	DictKind
+)
+
+// String satisfies the fmt.Stringer interface.
+func (k Kind) String() string {
+	switch k {
+	case IntegerKind:
+		return "integer"
+	case ByteStringKind:
+		return "bytestring"
+	case ListKind:
+		return "list"
+	case DictKind:
+		return "dict"
+	default:
+		return "invalid"
+	}
+}
+
+// Kind returns IntegerKind.
+func (i Integer) Kind() Kind { return IntegerKind }
+
+// AsInt returns the value of the Integer and true.
+func (i Integer) AsInt() (int64, bool) { return i.value, true }
+
+// AsBigInt returns the value of the Integer and true.
+func (i Integer) AsBigInt() (*big.Int, bool) { return big.NewInt(i.value), true }
+
+// AsString returns false.
+func (i Integer) AsString() (string, bool) { return "", false }
+
+// AsList returns false.
+func (i Integer) AsList() (List, bool) { return List{}, false }
+
+// AsDict returns false.
+func (i Integer) AsDict() (Dict, bool) { return Dict{}, false }
+
+// Kind returns IntegerKind.
+func (i BigInteger) Kind() Kind { return IntegerKind }
+
+// AsInt returns the value of the BigInteger and true,
+// if the value fits an int64.
+func (i BigInteger) AsInt() (int64, bool) {
+	v := i.Value()
+	return v.Int64(), v.IsInt64()
+}
+
+// AsBigInt returns the value of the BigInteger and true.
+func (i BigInteger) AsBigInt() (*big.Int, bool) { return i.Value(), true }
+
+// AsString returns false.
+func (i BigInteger) AsString() (string, bool) { return "", false }
+
+// AsList returns false.
+func (i BigInteger) AsList() (List, bool) { return List{}, false }
+
+// AsDict returns false.
+func (i BigInteger) AsDict() (Dict, bool) { return Dict{}, false }
+
+// Kind returns ByteStringKind.
+func (bs ByteString) Kind() Kind { return ByteStringKind }
+
+// AsInt returns false.
+func (bs ByteString) AsInt() (int64, bool) { return 0, false }
+
+// AsBigInt returns false.
+func (bs ByteString) AsBigInt() (*big.Int, bool) { return nil, false }
+
+// AsString returns the value of the ByteString and true.
+func (bs ByteString) AsString() (string, bool) { return bs.value, true }
+
+// AsList returns false.
+func (bs ByteString) AsList() (List, bool) { return List{}, false }
+
+// AsDict returns false.
+func (bs ByteString) AsDict() (Dict, bool) { return Dict{}, false }
+
+// Kind returns ListKind.
+func (l List) Kind() Kind { return ListKind }
+
+// AsInt returns false.
+func (l List) AsInt() (int64, bool) { return 0, false }
+
+// AsBigInt returns false.
+func (l List) AsBigInt() (*big.Int, bool) { return nil, false }
+
+// AsString returns false.
+func (l List) AsString() (string, bool) { return "", false }
+
+// AsList returns the List and true.
+func (l List) AsList() (List, bool) { return l, true }
+
+// AsDict returns false.
+func (l List) AsDict() (Dict, bool) { return Dict{}, false }
+
+// Kind returns DictKind.
+func (d Dict) Kind() Kind { return DictKind }
+
+// AsInt returns false.
+func (d Dict) AsInt() (int64, bool) { return 0, false }
+
+// AsBigInt returns false.
+func (d Dict) AsBigInt() (*big.Int, bool) { return nil, false }
+
+// AsString returns false.
+func (d Dict) AsString() (string, bool) { return "", false }
+
+// AsList returns false.
+func (d Dict) AsList() (List, bool) { return List{}, false }
+
+// AsDict returns the Dict and true.
+func (d Dict) AsDict() (Dict, bool) { return d, true }
+
+// nativeValue returns a representation of v using Go standard data types.
+func nativeValue(v Value) interface{} {
+	switch value := v.(type) {
+	case Integer:
+		return value.Value()
+	case BigInteger:
+		return value.Value()
+	case ByteString:
+		return value.Value()
+	case List:
+		return value.Value()
+	case Dict:
+		return value.Value()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,81 @@
+package bencode
+
+import (
+	"reflect"
+	"testing"
+)
+
+var valueKindTestCases = []struct {
+	name     string
+	input    Value
+	expected Kind
+}{
+	{"integer", Integer{1}, IntegerKind},
+	{"big integer", BigInteger{}, IntegerKind},
+	{"bytestring", ByteString{"a"}, ByteStringKind},
+	{"list", List{}, ListKind},
+	{"dict", Dict{}, DictKind},
+}
+
+func TestValueKind(t *testing.T) {
+	for _, tc := range valueKindTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.input.Kind(); got != tc.expected {
+				t.Fatalf("expected %v got %v\n", tc.expected, got)
+			}
+
+			_, isInt := tc.input.AsInt()
+			_, isString := tc.input.AsString()
+			_, isList := tc.input.AsList()
+			_, isDict := tc.input.AsDict()
+			got := []bool{isInt, isString, isList, isDict}
+
+			expected := make([]bool, 4)
+			expected[tc.expected] = true
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("expected %v got %v\n", expected, got)
+			}
+		})
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	v := Dict{map[ByteString]Value{
+		{"list"}: List{[]Value{Integer{42}, ByteString{"test"}}},
+	}}
+
+	d, ok := Value(v).AsDict()
+	if !ok {
+		t.Fatal("expected a dict")
+	}
+	l, ok := d.value[ByteString{"list"}].AsList()
+	if !ok {
+		t.Fatal("expected a list")
+	}
+	if i, ok := l.value[0].AsInt(); !ok || i != 42 {
+		t.Fatalf("expected %v got %v\n", 42, i)
+	}
+	if s, ok := l.value[1].AsString(); !ok || s != "test" {
+		t.Fatalf("expected %q got %q\n", "test", s)
+	}
+}
+
+func TestUnmarshalValue(t *testing.T) {
+	var got Value
+	if err := Unmarshal([]byte("li1e1:ae"), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := List{[]Value{Integer{1}, ByteString{"a"}}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v\n", expected, got)
+	}
+
+	buf, err := Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "li1e1:ae" {
+		t.Fatalf("expected %q got %q\n", "li1e1:ae", buf)
+	}
+}
@@ -0,0 +1,142 @@
+package bencode
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"unsafe"
+)
+
+// DecodeBytes decodes the single bencode value in data without copying
+// its bytestrings: the ByteString values in the result, including dict
+// keys, alias the memory of data.
+//
+// The caller must not modify data as long as the result, or any value
+// taken from it, is in use. Use Clone to obtain a copy of a value that
+// does not alias data.
+//
+// Like Unmarshal, DecodeBytes enforces DefaultLimits on its input and
+// returns an error wrapping ErrTrailingData if data holds more than one
+// value.
+func DecodeBytes(data []byte) (Value, error) {
+	bb := bytes.NewBuffer(data)
+	ds := newDecodeState(bb)
+	ds.alias = bb
+
+	if err := ds.checkInputSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+	v, err := ds.object()
+	if err != nil {
+		return nil, err
+	}
+	if bb.Len() > 0 {
+		return nil, ds.syntaxError(ds.off, "end of input", ErrTrailingData)
+	}
+
+	return v, nil
+}
+
+// readString reads exactly n bytes and returns them as a string. If the
+// input is aliased, the string shares its memory with the input.
+func (ds *decodeState) readString(n int) (string, error) {
+	if ds.alias == nil {
+		buf, err := ds.readN(n)
+		return string(buf), err
+	}
+
+	if err := ds.checkInputSize(int64(n)); err != nil {
+		return "", err
+	}
+
+	// mimic io.ReadFull on a short input
+	var err error
+	switch {
+	case ds.alias.Len() == 0 && n > 0:
+		err = io.EOF
+	case ds.alias.Len() < n:
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		n = ds.alias.Len()
+	}
+	buf := ds.alias.Next(n)
+	ds.off += int64(n)
+	if ds.capture != nil {
+		ds.capture.Write(buf)
+	}
+
+	return bytesToString(buf), err
+}
+
+// bytesToString returns a string sharing its memory with b.
+func bytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// cloneString returns a copy of s that does not share its memory.
+func cloneString(s string) string {
+	b := make([]byte, len(s))
+	copy(b, s)
+	return bytesToString(b)
+}
+
+// Clone returns a deep copy of v that does not share any memory with v,
+// e.g. with the input of DecodeBytes.
+func Clone(v Value) Value {
+	switch value := v.(type) {
+	case Integer:
+		return value
+	case BigInteger:
+		return value.Clone()
+	case ByteString:
+		return value.Clone()
+	case List:
+		return value.Clone()
+	case Dict:
+		return value.Clone()
+	default:
+		return nil
+	}
+}
+
+// Clone returns a copy of the BigInteger.
+func (i BigInteger) Clone() BigInteger {
+	if i.value == nil {
+		return i
+	}
+	return BigInteger{new(big.Int).Set(i.value)}
+}
+
+// Clone returns a copy of the ByteString that does not share its memory.
+func (bs ByteString) Clone() ByteString {
+	return ByteString{cloneString(bs.value)}
+}
+
+// Clone returns a deep copy of the List.
+func (l List) Clone() List {
+	if l.value == nil {
+		return l
+	}
+
+	values := make([]Value, len(l.value))
+	for i, v := range l.value {
+		values[i] = Clone(v)
+	}
+
+	return List{values}
+}
+
+// Clone returns a deep copy of the Dict, keys included.
+func (d Dict) Clone() Dict {
+	if d.value == nil {
+		return d
+	}
+
+	values := make(map[ByteString]Value, len(d.value))
+	for k, v := range d.value {
+		values[k.Clone()] = Clone(v)
+	}
+
+	return Dict{values}
+}
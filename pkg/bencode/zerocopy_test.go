@@ -0,0 +1,71 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeBytes(t *testing.T) {
+	data := []byte("d4:infod6:lengthi10e4:name4:filee4:listl1:a1:bee")
+
+	v, err := DecodeBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected Value
+	if err := Unmarshal(data, &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v got %v\n", expected, v)
+	}
+
+	cloned := Clone(v)
+
+	// the decoded bytestrings alias the input, the cloned ones do not
+	i := bytes.Index(data, []byte("4:file")) + 2
+	copy(data[i:], "elif")
+	name, _ := Query(v, "info.name")
+	if s, _ := name[0].AsString(); s != "elif" {
+		t.Fatalf("expected %q got %q\n", "elif", s)
+	}
+	if !reflect.DeepEqual(cloned, expected) {
+		t.Fatalf("expected %v got %v\n", expected, cloned)
+	}
+}
+
+var decodeBytesErrorTestCases = []struct {
+	name     string
+	input    string
+	expected error
+}{
+	{
+		name:     "truncated bytestring",
+		input:    "l4:te",
+		expected: io.ErrUnexpectedEOF,
+	},
+	{
+		name:     "missing bytestring",
+		input:    "4:",
+		expected: io.EOF,
+	},
+	{
+		name:     "trailing data",
+		input:    "1:ai1e",
+		expected: ErrTrailingData,
+	},
+}
+
+func TestDecodeBytesError(t *testing.T) {
+	for _, tc := range decodeBytesErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := DecodeBytes([]byte(tc.input)); !errors.Is(err, tc.expected) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}